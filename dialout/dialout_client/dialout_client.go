@@ -1,16 +1,19 @@
 package telemetry_dialout
 
 import (
-	// "encoding/json"
+	"bytes"
 	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/Azure/sonic-telemetry/metrics"
 	spb "github.com/Azure/sonic-telemetry/proto"
 	sdc "github.com/Azure/sonic-telemetry/sonic_data_client"
 	sdcfg "github.com/Azure/sonic-telemetry/sonic_db_config"
 	"github.com/go-redis/redis"
 	log "github.com/golang/glog"
 	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/gnmi/value"
 	"github.com/openconfig/ygot/ygot"
 	"github.com/Workiva/go-datastructures/queue"
 	"golang.org/x/net/context"
@@ -21,6 +24,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -100,7 +104,8 @@ type ClientConfig struct {
 	Encoding       gpb.Encoding
 	Unidirectional bool        // by default, no reponse from remote server
 	TLS            *tls.Config // TLS config to use when connecting to target. Optional.
-	RedisConType   string      // "unix"  or "tcp"
+	// Connections to redis, via unix socket if nil
+	RedisConn *sdc.RedisConnManager
 }
 
 // clientSubscription is the container for config data,
@@ -113,6 +118,11 @@ type clientSubscription struct {
 	paths         []*gpb.Path
 	reportType    reportType
 	interval      time.Duration // report interval
+	rerun         bool          // re-run completed once report upon config change
+	// Mode of stream report of translib paths
+	streamMode gpb.SubscriptionMode
+	// Unacknowledged notifications to resend in reliable mode, nil otherwise
+	resend *resendBuffer
 
 	// Running time data
 	cMu    sync.Mutex
@@ -123,6 +133,8 @@ type clientSubscription struct {
 	w      sync.WaitGroup       // Wait for all sub go routine to finish
 	opened bool                 // whether there is opened instance for this client subscription
 	cancel context.CancelFunc
+	// once report has been published
+	completed bool
 
 	conTryCnt uint64 //Number of time trying to connect
 	sendMsg   uint64
@@ -169,10 +181,17 @@ func (cs *clientSubscription) Close() {
 	log.V(2).Infof("Closed %v", cs)
 }
 
+// completedOnce tells whether the once report has been published
+func (cs *clientSubscription) completedOnce() bool {
+	cs.cMu.Lock()
+	defer cs.cMu.Unlock()
+	return cs.reportType == Once && cs.completed
+}
+
 func (cs *clientSubscription) NewInstance(ctx context.Context) error {
-	var useRedisLocal bool = false
 	cs.cMu.Lock()
 	defer cs.cMu.Unlock()
+	cs.completed = false
 
 	if cs.destGroupName == "" {
 		log.V(2).Infof("Destination group is not set for %v", cs)
@@ -186,17 +205,17 @@ func (cs *clientSubscription) NewInstance(ctx context.Context) error {
 	}
 
 	target := cs.prefix.GetTarget()
-	if target == "" {
-		return fmt.Errorf("Empty target data not supported yet")
-	}
 
 	// Connection to system data source
 	var dc sdc.Client
 	var err error
 	if target == "OTHERS" {
 		dc, err = sdc.NewNonDbClient(cs.paths, cs.prefix)
+	} else if sdc.IsDbTarget(target) {
+		dc, err = sdc.NewDbClient(cs.paths, cs.prefix, clientCfg.RedisConn)
 	} else {
-		dc, err = sdc.NewDbClient(cs.paths, cs.prefix, useRedisLocal)
+		// OpenConfig paths served by translib for any other target or no target
+		dc, err = sdc.NewTranslClient(cs.prefix, cs.paths)
 	}
 	if err != nil {
 		log.V(1).Infof("Connection to DB for %v failed: %v", *cs, err)
@@ -208,8 +227,18 @@ func (cs *clientSubscription) NewInstance(ctx context.Context) error {
 	return nil
 }
 
-// send runs until process Queue returns an error.
-func (cs *clientSubscription) send(stream spb.GNMIDialOut_PublishClient) error {
+// resendBuffer returns the buffer of unacknowledged notifications in reliable
+// mode, which needs acknowledgements, so it is nil if unidirectional.
+func (cs *clientSubscription) resendBuffer() *resendBuffer {
+	if clientCfg.Unidirectional {
+		return nil
+	}
+	return cs.resend
+}
+
+// send runs until process Queue returns an error. Notifications are kept in
+// resend buffer rb until acknowledged, if it is not nil.
+func (cs *clientSubscription) send(stream spb.GNMIDialOut_PublishClient, rb *resendBuffer) error {
 	for {
 		items, err := cs.q.Get(1)
 
@@ -230,12 +259,14 @@ func (cs *clientSubscription) send(stream spb.GNMIDialOut_PublishClient) error {
 				cs.errors++
 				return err
 			}
+			encodeResponse(resp, clientCfg.Encoding)
 		default:
 			log.V(1).Infof("Unknown data type %v for %s in queue", items[0], cs)
 			cs.errors++
 		}
 
 		cs.sendMsg++
+		rb.add(resp)
 		err = stream.Send(resp)
 		if err != nil {
 			log.V(1).Infof("Client %s sending error:%v", cs, err)
@@ -246,6 +277,145 @@ func (cs *clientSubscription) send(stream spb.GNMIDialOut_PublishClient) error {
 	}
 }
 
+// snapshot returns SubscribeResponse with current data of the paths
+func (cs *clientSubscription) snapshot() (*gpb.SubscribeResponse, error) {
+	spbValues, err := cs.dc.Get(nil)
+	if err != nil {
+		return nil, err
+	}
+	var updates []*gpb.Update
+	var spbValue *spb.Value
+	for _, spbValue = range spbValues {
+		update := &gpb.Update{
+			Path: spbValue.GetPath(),
+			Val:  spbValue.GetVal(),
+		}
+		updates = append(updates, update)
+	}
+	rs := &gpb.SubscribeResponse_Update{
+		Update: &gpb.Notification{
+			Timestamp: spbValue.GetTimestamp(),
+			Prefix:    cs.prefix,
+			Update:    updates,
+		},
+	}
+	response := &gpb.SubscribeResponse{Response: rs}
+	encodeResponse(response, clientCfg.Encoding)
+	return response, nil
+}
+
+// encodeResponse renders the values of the response in the encoding
+func encodeResponse(resp *gpb.SubscribeResponse, encoding gpb.Encoding) {
+	for _, update := range resp.GetUpdate().GetUpdate() {
+		update.Val = encodeValue(update.GetVal(), encoding)
+	}
+}
+
+// encodeValue returns the value, which data clients render in JSON, in the
+// encoding. With PROTO encoding JSON numbers and strings are typed, objects
+// are left in JSON_IETF. DB values are strings in redis, so they are mostly
+// StringVal.
+func encodeValue(val *gpb.TypedValue, encoding gpb.Encoding) *gpb.TypedValue {
+	j := val.GetJsonIetfVal()
+	if j == nil {
+		j = val.GetJsonVal()
+	}
+	if j == nil {
+		return val
+	}
+	switch encoding {
+	case gpb.Encoding_JSON:
+		return &gpb.TypedValue{Value: &gpb.TypedValue_JsonVal{JsonVal: j}}
+	case gpb.Encoding_BYTES:
+		return &gpb.TypedValue{Value: &gpb.TypedValue_BytesVal{BytesVal: j}}
+	case gpb.Encoding_ASCII:
+		var str string
+		if err := json.Unmarshal(j, &str); err != nil {
+			str = string(j)
+		}
+		return &gpb.TypedValue{Value: &gpb.TypedValue_AsciiVal{AsciiVal: str}}
+	case gpb.Encoding_PROTO:
+		var v interface{}
+		d := json.NewDecoder(bytes.NewReader(j))
+		d.UseNumber()
+		if err := d.Decode(&v); err != nil {
+			return val
+		}
+		if n, ok := v.(json.Number); ok {
+			// Counters are unsigned, and may be beyond the range of int64
+			if u, err := strconv.ParseUint(n.String(), 10, 64); err == nil {
+				v = u
+			} else if i, err := n.Int64(); err == nil {
+				v = i
+			} else if f, err := n.Float64(); err == nil {
+				v = f
+			}
+		}
+		if tv, err := value.FromScalar(v); err == nil {
+			return tv
+		}
+	}
+	return &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{JsonIetfVal: j}}
+}
+
+// subscriptionList returns SubscriptionList of the paths for stream report,
+// report interval is taken as sample interval in SAMPLE mode
+func (cs *clientSubscription) subscriptionList() *gpb.SubscriptionList {
+	subList := &gpb.SubscriptionList{
+		Prefix: cs.prefix,
+		Mode:   gpb.SubscriptionList_STREAM,
+	}
+	for _, path := range cs.paths {
+		sub := &gpb.Subscription{
+			Path: path,
+			Mode: cs.streamMode,
+		}
+		if cs.streamMode == gpb.SubscriptionMode_SAMPLE {
+			sub.SampleInterval = uint64(cs.interval)
+		}
+		subList.Subscription = append(subList.Subscription, sub)
+	}
+	return subList
+}
+
+// receiveAcks reads PublishResponse of the publish stream until it ends, then
+// closes done. Acknowledgements are tracked unless unidirectional, in which
+// case no PublishResponse is expected.
+func (cs *clientSubscription) receiveAcks(pub spb.GNMIDialOut_PublishClient, c *Client, done chan struct{}) {
+	defer close(done)
+	for {
+		ack, err := pub.Recv()
+		if err != nil {
+			log.V(2).Infof("Publish stream of %v ended: %v", cs.name, err)
+			return
+		}
+		if clientCfg.Unidirectional {
+			log.V(2).Infof("cs %s ignored unexpected PublishResponse %v", cs.name, ack)
+			continue
+		}
+		atomic.AddUint64(&cs.recvMsg, 1)
+		atomic.AddUint64(&c.recvMsg, 1)
+		metrics.DialoutAcks.WithLabelValues(cs.name).Inc()
+		log.V(6).Infof("cs %s received ack %v", cs.name, ack)
+		if cs.resend != nil && !cs.resend.ack(ack) {
+			log.V(2).Infof("cs %s received ack %v matching no unacknowledged notification", cs.name, ack)
+		}
+	}
+}
+
+// closePublish ends the publish stream and waits for the destination to end
+// it too, which is informed by closing of done, so data sent is not lost by
+// closing the connection.
+func closePublish(pub spb.GNMIDialOut_PublishClient, done <-chan struct{}) {
+	if err := pub.CloseSend(); err != nil {
+		return
+	}
+	select {
+	case <-done:
+	case <-time.After(clientCfg.RetryInterval):
+	}
+}
+
 // String returns the target the client is querying.
 func (cs *clientSubscription) String() string {
 	return fmt.Sprintf(" %s:%s:%s prefix %v paths %v interval %v, sendMsg %v, recvMsg %v",
@@ -265,6 +435,13 @@ func newClient(ctx context.Context, dest Destination) (*Client, error) {
 	opts := []grpc.DialOption{
 		grpc.WithBlock(),
 	}
+	if clientCfg.SrcIp != "" {
+		// Bind source address of the connection
+		dialer := &net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP(clientCfg.SrcIp)}}
+		opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "tcp", addr)
+		}))
+	}
 	if clientCfg.TLS != nil {
 		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(clientCfg.TLS)))
 	}
@@ -292,8 +469,19 @@ func publishRun(ctx context.Context, cs *clientSubscription, dests []Destination
 	var destNum, destIdx int
 	destNum = len(dests)
 	destIdx = 0
+	// Destination currently connected, for connection state metric
+	var connDest string
+	defer func() {
+		if connDest != "" {
+			metrics.DialoutConnected.WithLabelValues(cs.name, connDest).Set(0)
+		}
+	}()
 
 restart: //Remote server might go down, in that case we restart with next destination in the group
+	if connDest != "" {
+		metrics.DialoutConnected.WithLabelValues(cs.name, connDest).Set(0)
+		connDest = ""
+	}
 	cs.cMu.Lock()
 	cs.stop = make(chan struct{}, 1)
 	cs.q = queue.NewPriorityQueue(1, false)
@@ -302,6 +490,7 @@ restart: //Remote server might go down, in that case we restart with next destin
 	cs.cMu.Unlock()
 
 	cs.conTryCnt++
+	metrics.DialoutConnectAttempts.WithLabelValues(cs.name).Inc()
 	dest := dests[destIdx]
 	destIdx = (destIdx + 1) % destNum
 	c, err = newClient(ctx, dest)
@@ -329,6 +518,8 @@ restart: //Remote server might go down, in that case we restart with next destin
 	cs.cMu.Lock()
 	if cs.client == nil {
 		cs.client = c
+		connDest = dest.Addrs
+		metrics.DialoutConnected.WithLabelValues(cs.name, connDest).Set(1)
 	} else {
 		log.V(1).Infof("connection to %v already exists for %v, exiting publishRun", dest, cs)
 		c.Close()
@@ -336,38 +527,45 @@ restart: //Remote server might go down, in that case we restart with next destin
 		return
 	}
 	cs.cMu.Unlock()
+	acksDone := make(chan struct{})
+	go cs.receiveAcks(pub, c, acksDone)
+
+	// Unacknowledged notifications are published again first in reliable mode
+	rb := cs.resendBuffer()
+	if pending := rb.pending(); len(pending) > 0 {
+		log.V(1).Infof("Resending %v unacknowledged notifications of %v to %v", len(pending), cs.name, dest)
+		for _, response := range pending {
+			if err = pub.Send(response); err != nil {
+				log.V(1).Infof("Client %v pub Send error:%v, cs.conTryCnt %v", cs.name, err, cs.conTryCnt)
+				cs.Close()
+				// Don't restart immediatly
+				select {
+				case <-time.After(clientCfg.RetryInterval):
+					goto restart
+				case <-ctx.Done():
+					return
+				}
+			}
+			cs.sendMsg++
+			c.sendMsg++
+		}
+	}
 
 	switch cs.reportType {
 	case Periodic:
 		for {
 			select {
 			default:
-				spbValues, err := cs.dc.Get(nil)
+				response, err := cs.snapshot()
 				if err != nil {
 					// TODO: need to inform
 					log.V(2).Infof("Data read error %v for %v", err, cs)
 					continue
 					//return nil, status.Error(codes.NotFound, err.Error())
 				}
-				var updates []*gpb.Update
-				var spbValue *spb.Value
-				for _, spbValue = range spbValues {
-					update := &gpb.Update{
-						Path: spbValue.GetPath(),
-						Val:  spbValue.GetVal(),
-					}
-					updates = append(updates, update)
-				}
-				rs := &gpb.SubscribeResponse_Update{
-					Update: &gpb.Notification{
-						Timestamp: spbValue.GetTimestamp(),
-						Prefix:    cs.prefix,
-						Update:    updates,
-					},
-				}
-				response := &gpb.SubscribeResponse{Response: rs}
 
 				log.V(6).Infof("cs %s sending \n\t%v \n To %s", cs.name, response, dest)
+				rb.add(response)
 				err = pub.Send(response)
 				if err != nil {
 					log.V(1).Infof("Client %v pub Send error:%v, cs.conTryCnt %v", cs.name, err, cs.conTryCnt)
@@ -389,9 +587,9 @@ restart: //Remote server might go down, in that case we restart with next destin
 		select {
 		default:
 			cs.w.Add(1)
-			go cs.dc.StreamRun(cs.q, cs.stop, &cs.w, nil)
+			go cs.dc.StreamRun(cs.q, cs.stop, &cs.w, cs.subscriptionList())
 			time.Sleep(100 * time.Millisecond)
-			err = cs.send(pub)
+			err = cs.send(pub, rb)
 			if err != nil {
 				log.V(1).Infof("Client %v pub Send error:%v, cs.conTryCnt %v", cs.name, err, cs.conTryCnt)
 			}
@@ -405,6 +603,49 @@ restart: //Remote server might go down, in that case we restart with next destin
 			log.V(1).Infof("%v exiting publishRun routine for destination %s", cs, dest)
 			return
 		}
+	case Once:
+		// One full snapshot followed by sync_response, then the connection is
+		// closed. In reliable mode, the snapshot resent above is not taken again,
+		// and it must be acknowledged before completion.
+		if rb.len() == 0 {
+			var response *gpb.SubscribeResponse
+			response, err = cs.snapshot()
+			if err != nil {
+				log.V(2).Infof("Data read error %v for %v", err, cs)
+			} else {
+				log.V(6).Infof("cs %s sending \n\t%v \n To %s", cs.name, response, dest)
+				rb.add(response)
+				if err = pub.Send(response); err == nil {
+					cs.sendMsg++
+					c.sendMsg++
+				}
+			}
+		}
+		if err == nil {
+			err = pub.Send(&gpb.SubscribeResponse{Response: &gpb.SubscribeResponse_SyncResponse{SyncResponse: true}})
+		}
+		if err == nil {
+			closePublish(pub, acksDone)
+			if n := rb.len(); n > 0 {
+				err = fmt.Errorf("%v notifications not acknowledged", n)
+			}
+		}
+		if err != nil {
+			log.V(1).Infof("Once report of %v to %s failed: %v, cs.conTryCnt %v", cs.name, dest, err, cs.conTryCnt)
+			cs.Close()
+			// Don't restart immediatly
+			select {
+			case <-time.After(clientCfg.RetryInterval):
+				goto restart
+			case <-ctx.Done():
+				return
+			}
+		}
+		cs.cMu.Lock()
+		cs.completed = true
+		cs.cMu.Unlock()
+		cs.Close()
+		log.V(1).Infof("%v once report completed to destination %s", cs, dest)
 	default:
 		log.V(1).Infof("Unsupported report type %s in %v ", cs.reportType, cs)
 	}
@@ -427,11 +668,15 @@ restart: //Remote server might go down, in that case we restart with next destin
 
 	// Subscription group
 	Key         = TELEMETRY_CLIENT|Subscription_<name>
-	path_target = DbName
+	path_target = DbName                 ; translib paths if not DB name or not set
 	paths       = PATH1,PATH2        ;PATH separated by ","
 	dst_group   = <name>      ; // name of DestinationGroup
 	report_type = "periodic" / "stream" / "once"
 	report_interval = 1*8DIGIT      ; In millisecond,
+	rerun       = "true" / "false"   ; re-run completed once report upon config change, false by default
+	stream_mode = "target_defined" / "sample" / "on_change"   ; stream report mode of translib paths, target_defined by default
+	reliable    = "true" / "false"   ; resend unacknowledged notifications after reconnecting, false by default
+	resend_buffer = 1*8DIGIT         ; max number of unacknowledged notifications kept in reliable mode, 1000 by default
 */
 
 // closeDestGroupClient close client instances for all clientSubscription using
@@ -451,6 +696,10 @@ func closeDestGroupClient(destGroupName string) {
 func setupDestGroupClients(ctx context.Context, destGroupName string) {
 	if names, ok := DestGrp2ClientSubMap[destGroupName]; ok {
 		for _, name := range names {
+			if csub := ClientSubscriptionNameMap[name]; csub.completedOnce() && !csub.rerun {
+				log.V(2).Infof("Once report of %s is completed, not rerun with destGroup change", name)
+				continue
+			}
 			// Create a copy of Client subscription, existing one might be closing, don't interfere with it.
 			cs := *ClientSubscriptionNameMap[name]
 			log.V(2).Infof("NewInstance with destGroup change for %s to %s", name, destGroupName)
@@ -485,6 +734,10 @@ func processTelemetryClientConfig(ctx context.Context, redisDb *redis.Client, ke
 			for field, value := range fv {
 				switch field {
 				case "src_ip":
+					if value != "" && net.ParseIP(value) == nil {
+						log.V(2).Infof("Invalid src_ip %v", value)
+						continue
+					}
 					clientCfg.SrcIp = value
 				case "retry_interval":
 					//TODO: check validity of the interval
@@ -495,11 +748,19 @@ func processTelemetryClientConfig(ctx context.Context, redisDb *redis.Client, ke
 					}
 					clientCfg.RetryInterval = time.Second * time.Duration(itvl)
 				case "encoding":
-					//Flexible encoding Not supported yet
-					clientCfg.Encoding = gpb.Encoding_JSON_IETF
+					encoding, ok := gpb.Encoding_value[strings.ToUpper(value)]
+					if !ok {
+						log.V(2).Infof("Invalid encoding %v", value)
+						continue
+					}
+					clientCfg.Encoding = gpb.Encoding(encoding)
 				case "unidirectional":
-					// No PublishResponse supported yet
-					clientCfg.Unidirectional = true
+					unidirectional, err := strconv.ParseBool(value)
+					if err != nil {
+						log.V(2).Infof("Invalid unidirectional %v %v", value, err)
+						continue
+					}
+					clientCfg.Unidirectional = unidirectional
 				}
 			}
 			// Apply changes to all running instances
@@ -558,6 +819,11 @@ func processTelemetryClientConfig(ctx context.Context, redisDb *redis.Client, ke
 		}
 
 		if op == "hdel" {
+			if !ok {
+				// Subscription rejected for invalid config is not in the map
+				log.V(3).Infof("Client Subscription %v not found", name)
+				return nil
+			}
 			destGrpName := csub.destGroupName
 			// Remove this ClientSubscrition from the list of the Destination group users
 			csNames := DestGrp2ClientSubMap[destGrpName]
@@ -580,12 +846,32 @@ func processTelemetryClientConfig(ctx context.Context, redisDb *redis.Client, ke
 				name:     name,
 				cancel:   cancel,
 			}
+			reliable := false
+			resendSize := defaultResendBufferSize
 			for field, value := range fv {
 				switch field {
 				case "dst_group":
 					cs.destGroupName = value
 				case "report_type":
 					cs.reportType = NewReportType(value)
+				case "rerun":
+					cs.rerun = value == "true"
+				case "reliable":
+					reliable = value == "true"
+				case "resend_buffer":
+					size, err := strconv.ParseUint(value, 10, 32)
+					if err != nil || size == 0 {
+						log.V(2).Infof("Invalid resend_buffer %v %v", value, err)
+						continue
+					}
+					resendSize = int(size)
+				case "stream_mode":
+					mode, ok := gpb.SubscriptionMode_value[strings.ToUpper(value)]
+					if !ok {
+						log.V(2).Infof("Invalid stream_mode %v", value)
+						return fmt.Errorf("Invalid stream_mode %v", value)
+					}
+					cs.streamMode = gpb.SubscriptionMode(mode)
 				case "report_interval":
 					intvl, err := strconv.ParseUint(value, 10, 64)
 					if err != nil {
@@ -615,6 +901,15 @@ func processTelemetryClientConfig(ctx context.Context, redisDb *redis.Client, ke
 					return fmt.Errorf("Invalid field %v value %v", field, value)
 				}
 			}
+			if reliable {
+				if ok && csub.resend != nil {
+					// Notifications not acknowledged yet are still to be resent
+					cs.resend = csub.resend
+					cs.resend.setSize(resendSize)
+				} else {
+					cs.resend = newResendBuffer(cs.name, resendSize)
+				}
+			}
 			log.V(2).Infof("New clientSubscription %v", cs)
 			if cs.destGroupName == "" {
 				// not destination configured, just return
@@ -633,6 +928,12 @@ func processTelemetryClientConfig(ctx context.Context, redisDb *redis.Client, ke
 				DestGrp2ClientSubMap[cs.destGroupName] = append(DestGrp2ClientSubMap[cs.destGroupName], cs.name)
 			}
 			ClientSubscriptionNameMap[cs.name] = &cs
+			if ok && csub.completedOnce() && cs.reportType == Once && !cs.rerun {
+				// Keep the completed state, the once report is not published again
+				cs.completed = true
+				log.V(2).Infof("Once report of %s is completed, not rerun with Subscription change", cs.name)
+				return nil
+			}
 			log.V(2).Infof("NewInstance with Subscription change for %s to %s", cs.name, cs.destGroupName)
 			cs.NewInstance(ctx)
 		}
@@ -643,25 +944,17 @@ func processTelemetryClientConfig(ctx context.Context, redisDb *redis.Client, ke
 // read configDB data for telemetry client and start publishing service for client subscription
 func DialOutRun(ctx context.Context, ccfg *ClientConfig) error {
 	clientCfg = ccfg
-	dbn := sdcfg.GetDbId("CONFIG_DB")
-
-	var redisDb *redis.Client
-	if sdc.UseRedisLocalTcpPort == false {
-		redisDb = redis.NewClient(&redis.Options{
-			Network:     "unix",
-			Addr:        sdcfg.GetDbSock("CONFIG_DB"),
-			Password:    "", // no password set
-			DB:          dbn,
-			DialTimeout: 0,
-		})
-	} else {
-		redisDb = redis.NewClient(&redis.Options{
-			Network:     "tcp",
-			Addr:        sdcfg.GetDbTcpAddr("CONFIG_DB"),
-			Password:    "", // no password set
-			DB:          dbn,
-			DialTimeout: 0,
-		})
+	dbn, err := sdcfg.GetDbId("CONFIG_DB")
+	if err != nil {
+		return err
+	}
+
+	if clientCfg.RedisConn == nil {
+		clientCfg.RedisConn = sdc.NewRedisConnManager(sdc.RedisConfig{})
+	}
+	redisDb, err := clientCfg.RedisConn.Client(sdcfg.SONIC_DEFAULT_NAMESPACE, "CONFIG_DB")
+	if err != nil {
+		return err
 	}
 
 	separator, _ := sdc.GetTableKeySeparator("CONFIG_DB")
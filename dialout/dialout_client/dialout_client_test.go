@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"github.com/go-redis/redis"
 	//"github.com/golang/protobuf/proto"
+	spb "github.com/Azure/sonic-telemetry/proto"
 	testcert "github.com/Azure/sonic-telemetry/testdata/tls"
 
 	//"github.com/kylelemons/godebug/pretty"
@@ -21,17 +22,22 @@ import (
 	//"google.golang.org/grpc/status"
 	//"fmt"
 	"io/ioutil"
+	"net"
 	"os"
 	"os/exec"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	sds "github.com/Azure/sonic-telemetry/dialout/dialout_server"
+	"github.com/Azure/sonic-telemetry/metrics"
 	sdc "github.com/Azure/sonic-telemetry/sonic_data_client"
 	sdcfg "github.com/Azure/sonic-telemetry/sonic_db_config"
+	"github.com/golang/protobuf/proto"
 	gclient "github.com/openconfig/gnmi/client/gnmi"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 var clientTypes = []string{gclient.Type}
@@ -95,14 +101,22 @@ func runServer(t *testing.T, s *sds.Server) {
 }
 
 func getRedisClient(t *testing.T) *redis.Client {
+	addr, err := sdcfg.GetDbTcpAddr("COUNTERS_DB")
+	if err != nil {
+		t.Fatalf("failed to get redis config %v", err)
+	}
+	dbn, err := sdcfg.GetDbId("COUNTERS_DB")
+	if err != nil {
+		t.Fatalf("failed to get redis config %v", err)
+	}
 	rclient := redis.NewClient(&redis.Options{
 		Network:     "tcp",
-		Addr:        sdcfg.GetDbTcpAddr("COUNTERS_DB"),
+		Addr:        addr,
 		Password:    "", // no password set
-		DB:          sdcfg.GetDbId("COUNTERS_DB"),
+		DB:          dbn,
 		DialTimeout: 0,
 	})
-	_, err := rclient.Ping().Result()
+	_, err = rclient.Ping().Result()
 	if err != nil {
 		t.Fatal("failed to connect to redis server ", err)
 	}
@@ -124,14 +138,22 @@ func exe_cmd(t *testing.T, cmd string) {
 }
 
 func getConfigDbClient(t *testing.T) *redis.Client {
+	addr, err := sdcfg.GetDbTcpAddr("CONFIG_DB")
+	if err != nil {
+		t.Fatalf("failed to get redis config %v", err)
+	}
+	dbn, err := sdcfg.GetDbId("CONFIG_DB")
+	if err != nil {
+		t.Fatalf("failed to get redis config %v", err)
+	}
 	rclient := redis.NewClient(&redis.Options{
 		Network:     "tcp",
-		Addr:        sdcfg.GetDbTcpAddr("CONFIG_DB"),
+		Addr:        addr,
 		Password:    "", // no password set
-		DB:          sdcfg.GetDbId("CONFIG_DB"),
+		DB:          dbn,
 		DialTimeout: 0,
 	})
-	_, err := rclient.Ping().Result()
+	_, err = rclient.Ping().Result()
 	if err != nil {
 		t.Fatalf("failed to connect to redis server %v", err)
 	}
@@ -288,6 +310,9 @@ const (
 
 var s1, s2 *sds.Server
 
+// Whether the second collector acknowledges notifications, the first one never does
+var s2Ack bool
+
 func serverOp(t *testing.T, sop ServerOp) {
 	cfg := &sds.Config{Port: 8080}
 	var tmpStore []*pb.SubscribeResponse
@@ -302,13 +327,172 @@ func serverOp(t *testing.T, sop ServerOp) {
 		go runServer(t, s1)
 	case S2Start:
 		cfg.Port = 8081
+		cfg.Ack = s2Ack
 		s2 = createServer(t, cfg)
 		s2.SetDataStore(&tmpStore)
 		go runServer(t, s2)
 	}
 }
 
+func TestEncodeValue(t *testing.T) {
+	tests := []struct {
+		desc     string
+		json     string
+		encoding pb.Encoding
+		want     *pb.TypedValue
+	}{{
+		desc:     "JSON_IETF unchanged",
+		json:     `{"a":"1"}`,
+		encoding: pb.Encoding_JSON_IETF,
+		want:     &pb.TypedValue{Value: &pb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{"a":"1"}`)}},
+	}, {
+		desc:     "JSON",
+		json:     `{"a":"1"}`,
+		encoding: pb.Encoding_JSON,
+		want:     &pb.TypedValue{Value: &pb.TypedValue_JsonVal{JsonVal: []byte(`{"a":"1"}`)}},
+	}, {
+		desc:     "BYTES",
+		json:     `{"a":"1"}`,
+		encoding: pb.Encoding_BYTES,
+		want:     &pb.TypedValue{Value: &pb.TypedValue_BytesVal{BytesVal: []byte(`{"a":"1"}`)}},
+	}, {
+		desc:     "ASCII of string",
+		json:     `"up"`,
+		encoding: pb.Encoding_ASCII,
+		want:     &pb.TypedValue{Value: &pb.TypedValue_AsciiVal{AsciiVal: "up"}},
+	}, {
+		desc:     "ASCII of object",
+		json:     `{"a":"1"}`,
+		encoding: pb.Encoding_ASCII,
+		want:     &pb.TypedValue{Value: &pb.TypedValue_AsciiVal{AsciiVal: `{"a":"1"}`}},
+	}, {
+		desc:     "PROTO of number",
+		json:     `100`,
+		encoding: pb.Encoding_PROTO,
+		want:     &pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: 100}},
+	}, {
+		desc:     "PROTO of number beyond int64",
+		json:     `18446744073709551615`,
+		encoding: pb.Encoding_PROTO,
+		want:     &pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: 18446744073709551615}},
+	}, {
+		desc:     "PROTO of negative number",
+		json:     `-1`,
+		encoding: pb.Encoding_PROTO,
+		want:     &pb.TypedValue{Value: &pb.TypedValue_IntVal{IntVal: -1}},
+	}, {
+		desc:     "PROTO of float",
+		json:     `1.5`,
+		encoding: pb.Encoding_PROTO,
+		want:     &pb.TypedValue{Value: &pb.TypedValue_FloatVal{FloatVal: 1.5}},
+	}, {
+		desc:     "PROTO of DB value",
+		json:     `"100"`,
+		encoding: pb.Encoding_PROTO,
+		want:     &pb.TypedValue{Value: &pb.TypedValue_StringVal{StringVal: "100"}},
+	}, {
+		desc:     "PROTO of string",
+		json:     `"up"`,
+		encoding: pb.Encoding_PROTO,
+		want:     &pb.TypedValue{Value: &pb.TypedValue_StringVal{StringVal: "up"}},
+	}, {
+		desc:     "PROTO of object left in JSON_IETF",
+		json:     `{"a":"1"}`,
+		encoding: pb.Encoding_PROTO,
+		want:     &pb.TypedValue{Value: &pb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{"a":"1"}`)}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			val := &pb.TypedValue{Value: &pb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(tt.json)}}
+			if got := encodeValue(val, tt.encoding); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewClientSrcIp(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Addr, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- conn.RemoteAddr()
+		conn.Close()
+	}()
+
+	saved := clientCfg
+	defer func() { clientCfg = saved }()
+	clientCfg = &ClientConfig{
+		SrcIp:         "127.0.0.2",
+		RetryInterval: time.Second,
+		TLS:           &tls.Config{InsecureSkipVerify: true},
+	}
+	// The listener is no gNMIDialOut server, only the connection is checked
+	if c, err := newClient(context.Background(), Destination{Addrs: ln.Addr().String()}); err == nil {
+		c.Close()
+	}
+	select {
+	case addr := <-accepted:
+		if ip := addr.(*net.TCPAddr).IP.String(); ip != clientCfg.SrcIp {
+			t.Errorf("got connection from %v, want %v", ip, clientCfg.SrcIp)
+		}
+	default:
+		t.Errorf("got no connection from %v", clientCfg.SrcIp)
+	}
+}
+
 //
+func TestResendBuffer(t *testing.T) {
+	notification := func(ts int64, elem string) *pb.SubscribeResponse {
+		path := &pb.Path{Elem: []*pb.PathElem{{Name: elem}}}
+		return &pb.SubscribeResponse{Response: &pb.SubscribeResponse_Update{Update: &pb.Notification{
+			Timestamp: ts,
+			Update:    []*pb.Update{{Path: path}},
+		}}}
+	}
+	ack := func(ts int64, elem string) *spb.PublishResponse {
+		return &spb.PublishResponse{Timestamp: ts, Path: []*pb.Path{{Elem: []*pb.PathElem{{Name: elem}}}}}
+	}
+
+	rb := newResendBuffer("test", 2)
+	rb.add(notification(1, "a"))
+	rb.add(&pb.SubscribeResponse{Response: &pb.SubscribeResponse_SyncResponse{SyncResponse: true}})
+	rb.add(notification(2, "b"))
+	if rb.len() != 2 {
+		t.Fatalf("got %v notifications, want 2", rb.len())
+	}
+	if rb.ack(ack(2, "a")) {
+		t.Errorf("ack with different path matched")
+	}
+	if !rb.ack(ack(1, "a")) {
+		t.Errorf("ack of first notification not matched")
+	}
+	// The oldest is dropped when full
+	rb.add(notification(3, "c"))
+	rb.add(notification(4, "d"))
+	pending := rb.pending()
+	if len(pending) != 2 || pending[0].GetUpdate().GetTimestamp() != 3 || pending[1].GetUpdate().GetTimestamp() != 4 {
+		t.Errorf("got pending %v, want notifications at 3 and 4", pending)
+	}
+	rb.setSize(1)
+	if pending = rb.pending(); len(pending) != 1 || pending[0].GetUpdate().GetTimestamp() != 4 {
+		t.Errorf("got pending %v after resize, want notification at 4", pending)
+	}
+
+	var nilBuffer *resendBuffer
+	nilBuffer.add(notification(1, "a"))
+	if nilBuffer.len() != 0 || nilBuffer.pending() != nil {
+		t.Errorf("nil buffer is not empty")
+	}
+}
+
 func TestGNMIDialOutPublish(t *testing.T) {
 
 	fileName := "../../testdata/COUNTERS_PORT_NAME_MAP.txt"
@@ -347,6 +531,7 @@ func TestGNMIDialOutPublish(t *testing.T) {
 		Encoding:       pb.Encoding_JSON_IETF,
 		Unidirectional: true,
 		TLS:            &tls.Config{InsecureSkipVerify: true},
+		RedisConn:      testRedisConn,
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -355,7 +540,7 @@ func TestGNMIDialOutPublish(t *testing.T) {
 	exe_cmd(t, "redis-cli -n 4 hset TELEMETRY_CLIENT|Global retry_interval 5")
 	exe_cmd(t, "redis-cli -n 4 hset TELEMETRY_CLIENT|Global encoding JSON_IETF")
 	exe_cmd(t, "redis-cli -n 4 hset TELEMETRY_CLIENT|Global unidirectional true")
-	exe_cmd(t, "redis-cli -n 4 hset TELEMETRY_CLIENT|Global src_ip  30.57.185.38")
+	exe_cmd(t, "redis-cli -n 4 hset TELEMETRY_CLIENT|Global src_ip  127.0.0.1")
 
 	tests := []struct {
 		desc     string
@@ -364,6 +549,9 @@ func TestGNMIDialOutPublish(t *testing.T) {
 		sop      ServerOp         // Server operation done after commonds
 		updates  []tablePathValue // Update to db data
 		waitTime time.Duration    // Wait ftime after server operation
+		s2Ack    bool             // Second collector acknowledges notifications
+		// Notifications to the first collector are resent to the second one exactly once
+		wantResent bool
 
 		wantErr     bool
 		collector   string
@@ -442,19 +630,97 @@ func TestGNMIDialOutPublish(t *testing.T) {
 				},
 			},
 		},
+	}, {
+		desc: "DialOut once report to first collector",
+		cmds: []string{
+			"redis-cli -n 4 del TELEMETRY_CLIENT|Subscription_HS_RDMA",
+			"redis-cli -n 4 hset TELEMETRY_CLIENT|DestinationGroup_HS dst_addr 127.0.0.1:8080,127.0.0.1:8081",
+			"redis-cli -n 4 hmset TELEMETRY_CLIENT|Subscription_HS_ONCE path_target COUNTERS_DB dst_group HS report_type once paths COUNTERS/Ethernet*",
+		},
+		collector: "s1",
+		wantRespVal: []*pb.SubscribeResponse{
+			&pb.SubscribeResponse{
+				Response: &pb.SubscribeResponse_Update{
+					Update: &pb.Notification{
+						Update: []*pb.Update{
+							{Val: &pb.TypedValue{
+								Value: &pb.TypedValue_JsonIetfVal{
+									JsonIetfVal: countersEthernetWildcardByte,
+								}},
+							},
+						},
+					},
+				},
+			},
+			&pb.SubscribeResponse{
+				Response: &pb.SubscribeResponse_SyncResponse{
+					SyncResponse: true,
+				},
+			},
+		},
+	}, {
+		desc: "DialOut resends unacknowledged notifications to second collector in reliable mode",
+		cmds: []string{
+			"redis-cli -n 4 del TELEMETRY_CLIENT|Subscription_HS_ONCE",
+			"redis-cli -n 4 hset TELEMETRY_CLIENT|Global unidirectional false",
+			"redis-cli -n 4 hset TELEMETRY_CLIENT|DestinationGroup_HS dst_addr 127.0.0.1:8080,127.0.0.1:8081",
+			"redis-cli -n 4 hmset TELEMETRY_CLIENT|Subscription_HS_RELIABLE path_target COUNTERS_DB dst_group HS report_type stream paths COUNTERS/Ethernet*/SAI_PORT_STAT_PFC_7_RX_PKTS reliable true",
+		},
+		collector: "s2",
+		sop:       S1Stop,
+		updates: []tablePathValue{{
+			dbName:    "COUNTERS_DB",
+			tableName: "COUNTERS",
+			tableKey:  "oid:0x1000000000039", // "Ethernet68": "oid:0x1000000000039",
+			delimitor: ":",
+			field:     "SAI_PORT_STAT_PFC_7_RX_PKTS",
+			value:     "3", // be changed to 3 from 2
+		}, {
+			dbName:    "COUNTERS_DB",
+			tableName: "COUNTERS",
+			tableKey:  "oid:0x1000000000039", // "Ethernet68": "oid:0x1000000000039",
+			delimitor: ":",
+			field:     "SAI_PORT_STAT_PFC_7_RX_PKTS",
+			value:     "2", // be changed to 2 from 3
+		}},
+		waitTime:   clientCfg.RetryInterval + time.Second,
+		s2Ack:      true,
+		wantResent: true,
+		wantRespVal: []*pb.SubscribeResponse{
+			&pb.SubscribeResponse{
+				Response: &pb.SubscribeResponse_Update{
+					Update: &pb.Notification{
+						Update: []*pb.Update{
+							{Val: &pb.TypedValue{
+								Value: &pb.TypedValue_JsonIetfVal{
+									JsonIetfVal: countersEthernetWildcardPfcByte,
+								}},
+							},
+						},
+					},
+				},
+			},
+			&pb.SubscribeResponse{
+				Response: &pb.SubscribeResponse_SyncResponse{
+					SyncResponse: true,
+				},
+			},
+		},
 	}}
 
 	rclient := getRedisClient(t)
 	defer rclient.Close()
 	for _, tt := range tests {
 		prepareDb(t)
+		s2Ack = tt.s2Ack
 		serverOp(t, S1Start)
 		serverOp(t, S2Start)
 		t.Run(tt.desc, func(t *testing.T) {
-			var store []*pb.SubscribeResponse
+			var store, s1Store []*pb.SubscribeResponse
 			if tt.collector == "s1" {
 				s1.SetDataStore(&store)
 			} else {
+				s1.SetDataStore(&s1Store)
 				s2.SetDataStore(&store)
 			}
 			// Extra cmd preparation for this test case
@@ -494,15 +760,248 @@ func TestGNMIDialOutPublish(t *testing.T) {
 
 				}
 			}
+			if !tt.wantResent {
+				return
+			}
+			var sent int
+			for _, resp := range s1Store {
+				if resp.GetUpdate() == nil {
+					continue
+				}
+				sent++
+				var resent int
+				for _, got := range store {
+					if proto.Equal(got, resp) {
+						resent++
+					}
+				}
+				if resent != 1 {
+					t.Errorf("notification %v resent %v times, want once", resp, resent)
+				}
+			}
+			if sent == 0 {
+				t.Errorf("got no notification to first collector")
+			}
 		})
 		serverOp(t, S1Stop)
 		serverOp(t, S2Stop)
 	}
+	exe_cmd(t, "redis-cli -n 4 del TELEMETRY_CLIENT|Subscription_HS_RELIABLE")
+	exe_cmd(t, "redis-cli -n 4 hset TELEMETRY_CLIENT|Global unidirectional true")
+	s2Ack = false
 	cancel()
 
 }
 
-func init() {
-	// Inform gNMI server to use redis tcp localhost connection
-	sdc.UseRedisLocalTcpPort = true
+func TestGNMIDialOutAck(t *testing.T) {
+	prepareDb(t)
+	clientCfg := ClientConfig{
+		SrcIp:          "",
+		RetryInterval:  5 * time.Second,
+		Encoding:       pb.Encoding_JSON_IETF,
+		Unidirectional: false,
+		TLS:            &tls.Config{InsecureSkipVerify: true},
+		RedisConn:      testRedisConn,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	exe_cmd(t, "redis-cli -n 4 hset TELEMETRY_CLIENT|Global unidirectional false")
+	defer exe_cmd(t, "redis-cli -n 4 hset TELEMETRY_CLIENT|Global unidirectional true")
+	go DialOutRun(ctx, &clientCfg)
+
+	s2Ack = true
+	serverOp(t, S2Start)
+	defer func() {
+		serverOp(t, S2Stop)
+		s2Ack = false
+	}()
+	var store []*pb.SubscribeResponse
+	s2.SetDataStore(&store)
+
+	exe_cmd(t, "redis-cli -n 4 hset TELEMETRY_CLIENT|DestinationGroup_ACK dst_addr 127.0.0.1:8081")
+	exe_cmd(t, "redis-cli -n 4 hmset TELEMETRY_CLIENT|Subscription_ACK path_target COUNTERS_DB dst_group ACK report_type stream paths COUNTERS/Ethernet68/SAI_PORT_STAT_PFC_7_RX_PKTS")
+	defer exe_cmd(t, "redis-cli -n 4 del TELEMETRY_CLIENT|DestinationGroup_ACK")
+	defer exe_cmd(t, "redis-cli -n 4 del TELEMETRY_CLIENT|Subscription_ACK")
+	time.Sleep(time.Second)
+
+	rclient := getRedisClient(t)
+	defer rclient.Close()
+	for _, value := range []string{"3", "2"} {
+		rclient.HSet("COUNTERS:oid:0x1000000000039", "SAI_PORT_STAT_PFC_7_RX_PKTS", value)
+		time.Sleep(time.Millisecond * 500)
+	}
+	time.Sleep(time.Millisecond * 500)
+
+	// The collector acknowledges each notification, sync_response is not
+	var notifications int
+	for _, resp := range store {
+		if resp.GetUpdate() != nil {
+			notifications++
+		}
+	}
+	if notifications < 3 {
+		t.Errorf("got %v notifications, want the snapshot and 2 updates", notifications)
+	}
+	if acks := testutil.ToFloat64(metrics.DialoutAcks.WithLabelValues("ACK")); acks != float64(notifications) {
+		t.Errorf("got %v acks, want %v", acks, notifications)
+	}
+}
+
+// prepareDbTranslib loads the DB dump which translib paths are served from
+func prepareDbTranslib(t *testing.T) {
+	fileName := "../../testdata/db_dump.json"
+	dbDumpByte, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatalf("read file %v err: %v", fileName, err)
+	}
+	var rj []map[string]interface{}
+	if err = json.Unmarshal(dbDumpByte, &rj); err != nil {
+		t.Fatalf("unmarshal %v err: %v", fileName, err)
+	}
+	addr, err := sdcfg.GetDbTcpAddr("CONFIG_DB")
+	if err != nil {
+		t.Fatalf("failed to get redis config %v", err)
+	}
+	for n, mpi := range rj {
+		rclient := redis.NewClient(&redis.Options{Network: "tcp", Addr: addr, DB: n})
+		for key, fv := range mpi {
+			if fv, ok := fv.(map[string]interface{}); ok {
+				rclient.HMSet(key, fv)
+			}
+		}
+		rclient.Close()
+	}
+}
+
+func TestStreamModeConfig(t *testing.T) {
+	configDb := getConfigDbClient(t)
+	defer configDb.Close()
+	key := "Subscription_MODE"
+	defer func() {
+		configDb.Del("TELEMETRY_CLIENT|" + key)
+		processTelemetryClientConfig(context.Background(), configDb, key, "hdel")
+	}()
+
+	tests := []struct {
+		mode    string
+		want    pb.SubscriptionMode
+		wantErr bool
+	}{
+		{"", pb.SubscriptionMode_TARGET_DEFINED, false},
+		{"target_defined", pb.SubscriptionMode_TARGET_DEFINED, false},
+		{"sample", pb.SubscriptionMode_SAMPLE, false},
+		{"ON_CHANGE", pb.SubscriptionMode_ON_CHANGE, false},
+		{"every_second", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run("stream_mode "+tt.mode, func(t *testing.T) {
+			configDb.Del("TELEMETRY_CLIENT|" + key)
+			fv := map[string]interface{}{
+				"path_target":     "OC_YANG",
+				"paths":           "openconfig-interfaces:interfaces",
+				"report_type":     "stream",
+				"report_interval": "2000",
+				// No such group, so that nothing is published
+				"dst_group": "MODE",
+			}
+			if tt.mode != "" {
+				fv["stream_mode"] = tt.mode
+			}
+			configDb.HMSet("TELEMETRY_CLIENT|"+key, fv)
+			err := processTelemetryClientConfig(context.Background(), configDb, key, "hset")
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got no error of invalid stream_mode")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			subList := ClientSubscriptionNameMap["MODE"].subscriptionList()
+			if len(subList.Subscription) != 1 {
+				t.Fatalf("got subscription list %v, want one subscription", subList)
+			}
+			sub := subList.Subscription[0]
+			if sub.Mode != tt.want {
+				t.Errorf("got mode %v, want %v", sub.Mode, tt.want)
+			}
+			// Report interval is the sample interval in SAMPLE mode
+			if tt.want == pb.SubscriptionMode_SAMPLE && sub.SampleInterval != uint64(2*time.Second) {
+				t.Errorf("got sample interval %v, want %v", sub.SampleInterval, uint64(2*time.Second))
+			}
+		})
+	}
+}
+
+func TestGNMIDialOutTranslib(t *testing.T) {
+	prepareDbTranslib(t)
+	clientCfg := ClientConfig{
+		SrcIp:          "",
+		RetryInterval:  5 * time.Second,
+		Encoding:       pb.Encoding_JSON_IETF,
+		Unidirectional: true,
+		TLS:            &tls.Config{InsecureSkipVerify: true},
+		RedisConn:      testRedisConn,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go DialOutRun(ctx, &clientCfg)
+
+	serverOp(t, S1Start)
+	defer serverOp(t, S1Stop)
+	exe_cmd(t, "redis-cli -n 4 hset TELEMETRY_CLIENT|DestinationGroup_OC dst_addr 127.0.0.1:8080")
+
+	ocPath := "openconfig-interfaces:interfaces/interface[name=Ethernet4]/state/admin-status"
+	tests := []struct {
+		desc       string
+		target     string
+		reportType string
+	}{
+		{"periodic report of OC_YANG target", "OC_YANG", "periodic"},
+		{"once report of OC_YANG target", "OC_YANG", "once"},
+		{"stream report of OC_YANG target", "OC_YANG", "stream"},
+		{"periodic report without target", "", "periodic"},
+		{"once report without target", "", "once"},
+		{"stream report without target", "", "stream"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			var store []*pb.SubscribeResponse
+			s1.SetDataStore(&store)
+			key := "TELEMETRY_CLIENT|Subscription_OC" + strconv.Itoa(i)
+			cmd := "redis-cli -n 4 hmset " + key + " dst_group OC report_interval 1000 report_type " + tt.reportType + " paths " + ocPath
+			if tt.target != "" {
+				cmd += " path_target " + tt.target
+			}
+			exe_cmd(t, cmd)
+			time.Sleep(3 * time.Second)
+			exe_cmd(t, "redis-cli -n 4 del "+key)
+			time.Sleep(500 * time.Millisecond)
+
+			var updates, syncs int
+			for _, resp := range store {
+				switch resp.GetResponse().(type) {
+				case *pb.SubscribeResponse_SyncResponse:
+					syncs++
+				case *pb.SubscribeResponse_Update:
+					for _, update := range resp.GetUpdate().GetUpdate() {
+						if !strings.Contains(string(update.GetVal().GetJsonIetfVal()), "admin-status") {
+							t.Errorf("got update %v, want admin-status", update)
+						}
+						updates++
+					}
+				}
+			}
+			if updates == 0 {
+				t.Errorf("got no update of %v", ocPath)
+			}
+			if tt.reportType != "periodic" && syncs == 0 {
+				t.Errorf("got no sync response")
+			}
+		})
+	}
 }
+
+// Redis connections of the tests, via tcp localhost
+var testRedisConn = sdc.NewRedisConnManager(sdc.RedisConfig{UseTcp: true})
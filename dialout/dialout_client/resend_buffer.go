@@ -0,0 +1,128 @@
+package telemetry_dialout
+
+import (
+	"sync"
+
+	"github.com/Azure/sonic-telemetry/metrics"
+	spb "github.com/Azure/sonic-telemetry/proto"
+	log "github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Default number of unacknowledged notifications kept for a subscription
+// in reliable mode
+const defaultResendBufferSize = 1000
+
+// resendBuffer keeps the notifications published in reliable mode until the
+// collector acknowledges them with PublishResponse, they are published again
+// after reconnecting to the same or the next destination. It is bounded, the
+// oldest notifications are dropped when it is full.
+type resendBuffer struct {
+	name  string // subscription name, for metrics
+	mu    sync.Mutex
+	size  int
+	items []*gpb.SubscribeResponse
+}
+
+func newResendBuffer(name string, size int) *resendBuffer {
+	return &resendBuffer{
+		name: name,
+		size: size,
+	}
+}
+
+// setSize changes the bound of the buffer, dropping the oldest notifications
+// beyond it
+func (rb *resendBuffer) setSize(size int) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.size = size
+	rb.trim(size)
+}
+
+// trim drops the oldest notifications until at most n are left
+func (rb *resendBuffer) trim(n int) {
+	for len(rb.items) > n && len(rb.items) > 0 {
+		log.V(1).Infof("Resend buffer of %v full, dropped unacknowledged notification at %v",
+			rb.name, rb.items[0].GetUpdate().GetTimestamp())
+		metrics.DialoutResendDropped.WithLabelValues(rb.name).Inc()
+		rb.items = rb.items[1:]
+	}
+	metrics.DialoutUnacked.WithLabelValues(rb.name).Set(float64(len(rb.items)))
+}
+
+// add keeps the notification until acknowledged, other responses like
+// sync_response are not acknowledged. It is no-op on nil buffer.
+func (rb *resendBuffer) add(resp *gpb.SubscribeResponse) {
+	if rb == nil || resp.GetUpdate() == nil {
+		return
+	}
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.trim(rb.size - 1)
+	rb.items = append(rb.items, resp)
+	metrics.DialoutUnacked.WithLabelValues(rb.name).Set(float64(len(rb.items)))
+}
+
+// ack removes the oldest notification matching timestamp and paths of the
+// PublishResponse. It returns false if none matches.
+func (rb *resendBuffer) ack(pr *spb.PublishResponse) bool {
+	if rb == nil {
+		return false
+	}
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	for i, item := range rb.items {
+		n := item.GetUpdate()
+		if n.GetTimestamp() != pr.GetTimestamp() || !samePaths(notificationPaths(n), pr.GetPath()) {
+			continue
+		}
+		rb.items = append(rb.items[:i:i], rb.items[i+1:]...)
+		metrics.DialoutUnacked.WithLabelValues(rb.name).Set(float64(len(rb.items)))
+		return true
+	}
+	return false
+}
+
+// pending returns the unacknowledged notifications, oldest first
+func (rb *resendBuffer) pending() []*gpb.SubscribeResponse {
+	if rb == nil {
+		return nil
+	}
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return append([]*gpb.SubscribeResponse(nil), rb.items...)
+}
+
+// len returns the number of unacknowledged notifications
+func (rb *resendBuffer) len() int {
+	if rb == nil {
+		return 0
+	}
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return len(rb.items)
+}
+
+// notificationPaths returns the paths updated and deleted by the notification,
+// which are acknowledged in PublishResponse
+func notificationPaths(n *gpb.Notification) []*gpb.Path {
+	var paths []*gpb.Path
+	for _, update := range n.GetUpdate() {
+		paths = append(paths, update.GetPath())
+	}
+	return append(paths, n.GetDelete()...)
+}
+
+func samePaths(a, b []*gpb.Path) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !proto.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -5,6 +5,8 @@ import (
 	"crypto/tls"
 	"flag"
 	dc "github.com/Azure/sonic-telemetry/dialout/dialout_client"
+	"github.com/Azure/sonic-telemetry/metrics"
+	sdc "github.com/Azure/sonic-telemetry/sonic_data_client"
 	log "github.com/golang/glog"
 	gpb "github.com/openconfig/gnmi/proto/gnmi"
 	"golang.org/x/net/context"
@@ -21,6 +23,8 @@ var (
 		Unidirectional: true,
 		TLS:            &tls.Config{},
 	}
+	metricsPort = flag.Int("metrics_port", 0, "Port to serve Prometheus metrics on. Disabled if 0")
+	dbConfig    = flag.String("db_config", "", "Path of database_config.json, overriding environment variable SONIC_DB_CONFIG_FILE and the default path")
 )
 
 func init() {
@@ -32,6 +36,12 @@ func init() {
 
 func main() {
 	flag.Parse()
+	if errs := sdc.InitDbConfig(*dbConfig); len(errs) > 0 {
+		for _, err := range errs {
+			log.Errorf("Invalid database config: %v", err)
+		}
+		log.Exitf("Telemetry publish client needs valid database config")
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	// Terminate on Ctrl+C
 	go func() {
@@ -40,6 +50,15 @@ func main() {
 		<-c
 		cancel()
 	}()
+	if *metricsPort > 0 {
+		go func() {
+			if err := metrics.Serve(*metricsPort, nil); err != nil {
+				log.Errorf("Failed to serve metrics: %v", err)
+			}
+		}()
+	}
+	clientCfg.RedisConn = sdc.NewRedisConnManager(sdc.RedisConfig{})
+	defer clientCfg.RedisConn.Close()
 	log.V(1).Infof("Starting telemetry publish client")
 	err := dc.DialOutRun(ctx, &clientCfg)
 	log.V(1).Infof("Exiting telemetry publish client: %v", err)
@@ -38,6 +38,9 @@ type Config struct {
 	// Port for the Server to listen on. If 0 or unset the Server will pick a port
 	// for this Server.
 	Port int64
+	// Acknowledge each received notification with PublishResponse, for
+	// clients which are not unidirectional
+	Ack bool
 }
 
 // New returns an initialized Server.
@@ -211,7 +214,20 @@ func (c *Client) Run(srv *Server, stream spb.GNMIDialOut_PublishServer) (err err
 			utils.PrintProto(subscribeResponse)
 		}
 
-		// TODO: send back (m *PublishResponse))
+		if notification := subscribeResponse.GetUpdate(); notification != nil && srv.config.Ack {
+			ack := &spb.PublishResponse{
+				Timestamp: notification.GetTimestamp(),
+				Prefix:    notification.GetPrefix(),
+			}
+			for _, update := range notification.GetUpdate() {
+				ack.Path = append(ack.Path, update.GetPath())
+			}
+			ack.Path = append(ack.Path, notification.GetDelete()...)
+			if err = stream.Send(ack); err != nil {
+				return grpc.Errorf(grpc.Code(err), "failed to send ack to client")
+			}
+			c.sendMsg++
+		}
 	}
 	return grpc.Errorf(codes.InvalidArgument, "Exiting")
 }
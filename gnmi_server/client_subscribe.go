@@ -1,24 +1,37 @@
 package gnmi
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	log "github.com/golang/glog"
 	"github.com/Workiva/go-datastructures/queue"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 
+	"github.com/Azure/sonic-telemetry/metrics"
 	sdc "github.com/Azure/sonic-telemetry/sonic_data_client"
+	"github.com/golang/protobuf/proto"
 	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/ygot/ygot"
+)
+
+const (
+	// Maximum number of pending queue entries taken for one coalescing pass.
+	coalesceBatch int = 1024
 )
 
 // Client contains information about a subscribe client that has connected to the server.
 type Client struct {
 	addr      net.Addr
+	start     time.Time
 	sendMsg   int64
+	sendBytes int64
 	recvMsg   int64
 	errors    int64
 	polled    chan struct{}
@@ -30,14 +43,22 @@ type Client struct {
 	// Wait for all sub go routine to finish
 	w     sync.WaitGroup
 	fatal bool
+	// Coalesce pending updates by path, only the newest value is sent. It is
+	// for stream subscriptions whose paths are all SAMPLE or ON_CHANGE.
+	coalesce bool
+	// Closed because the server is shutting down
+	stopping bool
+	// Connections to redis for DB clients
+	conn *sdc.RedisConnManager
 }
 
 // NewClient returns a new initialized client.
 func NewClient(addr net.Addr) *Client {
 	pq := queue.NewPriorityQueue(1, false)
 	return &Client{
-		addr: addr,
-		q:    pq,
+		addr:  addr,
+		start: time.Now(),
+		q:     pq,
 	}
 }
 
@@ -46,6 +67,52 @@ func (c *Client) String() string {
 	return c.addr.String()
 }
 
+// sessionStats is the statistics of one subscribe client, rendered
+// in JSON for path OTHERS/telemetry/sessions
+type sessionStats struct {
+	Peer       string   `json:"peer"`
+	Target     string   `json:"target"`
+	Mode       string   `json:"mode"`
+	Paths      []string `json:"paths"`
+	QueueDepth int      `json:"queue_depth"`
+	SendMsg    int64    `json:"send_msg"`
+	SendBytes  int64    `json:"send_bytes"`
+	RecvMsg    int64    `json:"recv_msg"`
+	Errors     int64    `json:"errors"`
+	StartTime  string   `json:"start_time"`
+}
+
+// stats returns a snapshot of the client statistics.
+func (c *Client) stats() sessionStats {
+	st := sessionStats{
+		Peer:      c.String(),
+		SendMsg:   atomic.LoadInt64(&c.sendMsg),
+		SendBytes: atomic.LoadInt64(&c.sendBytes),
+		RecvMsg:   atomic.LoadInt64(&c.recvMsg),
+		Errors:    atomic.LoadInt64(&c.errors),
+		StartTime: c.start.Format(time.RFC3339),
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.q != nil && !c.q.Disposed() {
+		st.QueueDepth = c.q.Len()
+	}
+	if c.subscribe != nil {
+		st.Target = c.subscribe.GetPrefix().GetTarget()
+		st.Mode = c.subscribe.GetMode().String()
+		for _, sub := range c.subscribe.GetSubscription() {
+			p, err := ygot.PathToString(sub.GetPath())
+			if err != nil {
+				log.V(2).Infof("Client %s invalid path %v: %v", c, sub.GetPath(), err)
+				continue
+			}
+			st.Paths = append(st.Paths, p)
+		}
+	}
+	return st
+}
+
 // Populate SONiC data path from prefix and subscription path.
 func (c *Client) populateDbPathSubscrition(sublist *gnmipb.SubscriptionList) ([]*gnmipb.Path, error) {
 	var paths []*gnmipb.Path
@@ -80,12 +147,12 @@ func (c *Client) Run(stream gnmipb.GNMI_SubscribeServer) (err error) {
 
 	defer func() {
 		if err != nil {
-			c.errors++
+			atomic.AddInt64(&c.errors, 1)
 		}
 	}()
 
 	query, err := stream.Recv()
-	c.recvMsg++
+	atomic.AddInt64(&c.recvMsg, 1)
 	if err != nil {
 		if err == io.EOF {
 			return grpc.Errorf(codes.Aborted, "stream EOF received before init")
@@ -95,7 +162,9 @@ func (c *Client) Run(stream gnmipb.GNMI_SubscribeServer) (err error) {
 
 	log.V(2).Infof("Client %s recieved initial query %v", c, query)
 
+	c.mu.Lock()
 	c.subscribe = query.GetSubscribe()
+	c.mu.Unlock()
 	if c.subscribe == nil {
 		return grpc.Errorf(codes.InvalidArgument, "first message must be SubscriptionList: %q", query)
 	}
@@ -120,8 +189,8 @@ func (c *Client) Run(stream gnmipb.GNMI_SubscribeServer) (err error) {
 
 	if target == "OTHERS" {
 		dc, err = sdc.NewNonDbClient(paths, prefix)
-	} else if isTargetDb(target) == true {
-		dc, err = sdc.NewDbClient(paths, prefix, true)
+	} else if sdc.IsDbTarget(target) {
+		dc, err = sdc.NewDbClient(paths, prefix, c.conn)
 	} else {
 		/* For any other target or no target create new Transl Client. */
 		dc, err = sdc.NewTranslClient(prefix, paths, true)
@@ -132,8 +201,13 @@ func (c *Client) Run(stream gnmipb.GNMI_SubscribeServer) (err error) {
 	}
 
 
-	switch mode := c.subscribe.GetMode(); mode {
+	mode := c.subscribe.GetMode()
+	metrics.ActiveSubscriptions.WithLabelValues(target, mode.String()).Inc()
+	defer metrics.ActiveSubscriptions.WithLabelValues(target, mode.String()).Dec()
+
+	switch mode {
 	case gnmipb.SubscriptionList_STREAM:
+		c.coalesce = coalescable(c.subscribe)
 		c.stop = make(chan struct{}, 1)
 		c.w.Add(1)
 		go dc.StreamRun(c.q, c.stop, &c.w, c.subscribe)
@@ -157,16 +231,33 @@ func (c *Client) Run(stream gnmipb.GNMI_SubscribeServer) (err error) {
 	c.Close()
 	// Wait until all child go routines exited
 	c.w.Wait()
+
+	c.mu.RLock()
+	stopping := c.stopping
+	c.mu.RUnlock()
+	if stopping {
+		return grpc.Errorf(codes.Unavailable, "telemetry server is shutting down")
+	}
 	return grpc.Errorf(codes.InvalidArgument, "%s", err)
 }
 
+// shutdown closes the client as the server is shutting down, the collector
+// is informed with codes.Unavailable.
+func (c *Client) shutdown() {
+	c.mu.Lock()
+	c.stopping = true
+	c.mu.Unlock()
+	log.V(1).Infof("Client %s shutdown by server", c)
+	c.Close()
+}
+
 // Closing of client queue is triggered upon end of stream receive or stream error
 // or fatal error of any client go routine .
 // it will cause cancle of client context and exit of the send goroutines.
 func (c *Client) Close() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	log.V(1).Infof("Client %s Close, sendMsg %v recvMsg %v errors %v", c, c.sendMsg, c.recvMsg, c.errors)
+	log.V(1).Infof("Client %s Close, sendMsg %v recvMsg %v errors %v", c, atomic.LoadInt64(&c.sendMsg), atomic.LoadInt64(&c.recvMsg), atomic.LoadInt64(&c.errors))
 	if c.q != nil {
 		if c.q.Disposed() {
 			return
@@ -190,7 +281,7 @@ func (c *Client) recv(stream gnmipb.GNMI_SubscribeServer) {
 	for {
 		log.V(5).Infof("Client %s blocking on stream.Recv()", c)
 		event, err := stream.Recv()
-		c.recvMsg++
+		atomic.AddInt64(&c.recvMsg, 1)
 
 		switch err {
 		default:
@@ -218,37 +309,146 @@ func (c *Client) recv(stream gnmipb.GNMI_SubscribeServer) {
 // send runs until process Queue returns an error.
 func (c *Client) send(stream gnmipb.GNMI_SubscribeServer) error {
 	for {
-		items, err := c.q.Get(1)
+		var items []queue.Item
+		var err error
+		if c.coalesce {
+			items, err = c.q.Get(coalesceBatch)
+		} else {
+			items, err = c.q.Get(1)
+		}
 
 		if items == nil {
 			log.V(1).Infof("%v", err)
 			return err
 		}
 		if err != nil {
-			c.errors++
+			atomic.AddInt64(&c.errors, 1)
 			log.V(1).Infof("%v", err)
 			return fmt.Errorf("unexpected queue Gext(1): %v", err)
 		}
+		if c.coalesce {
+			items = coalesceItems(items)
+		}
+
+		for _, item := range items {
+			var resp *gnmipb.SubscribeResponse
+			switch v := item.(type) {
+			case sdc.Value:
+				if resp, err = sdc.ValToResp(v); err != nil {
+					atomic.AddInt64(&c.errors, 1)
+					return err
+				}
+			default:
+				log.V(1).Infof("Unknown data type %v for %s in queue", item, c)
+				atomic.AddInt64(&c.errors, 1)
+			}
 
-		var resp *gnmipb.SubscribeResponse
-		switch v := items[0].(type) {
-		case sdc.Value:
-			if resp, err = sdc.ValToResp(v); err != nil {
-				c.errors++
+			atomic.AddInt64(&c.sendMsg, 1)
+			atomic.AddInt64(&c.sendBytes, int64(proto.Size(resp)))
+			err = stream.Send(resp)
+			if err != nil {
+				log.V(1).Infof("Client %s sending error:%v", c, err)
+				atomic.AddInt64(&c.errors, 1)
 				return err
 			}
-		default:
-			log.V(1).Infof("Unknown data type %v for %s in queue", items[0], c)
-			c.errors++
+			log.V(5).Infof("Client %s done sending, msg count %d, msg %v", c, atomic.LoadInt64(&c.sendMsg), resp)
 		}
+	}
+}
 
-		c.sendMsg++
-		err = stream.Send(resp)
-		if err != nil {
-			log.V(1).Infof("Client %s sending error:%v", c, err)
-			c.errors++
-			return err
+// coalesceItems drops pending values of the same path superseded by a newer
+// one, so that only the newest value is kept. The newest value stays at its
+// position, so the timestamp ordering of the queue is preserved. Values are
+// never merged across a sync_response, a fatal message or a delete, which
+// keeps initial data ahead of the sync_response it belongs to.
+func coalesceItems(items []queue.Item) []queue.Item {
+	if len(items) < 2 {
+		return items
+	}
+
+	var out []queue.Item
+	// Index in out of the pending value for each path since the last barrier
+	pending := make(map[string]int)
+	for _, item := range items {
+		v, ok := item.(sdc.Value)
+		if !ok || v.GetSyncResponse() || v.GetFatal() != "" || v.GetPath() == nil || v.GetVal() == nil {
+			pending = make(map[string]int)
+			out = append(out, item)
+			continue
+		}
+		// All values of one client share the same prefix, path is enough.
+		key := proto.CompactTextString(v.GetPath())
+		if idx, ok := pending[key]; ok {
+			if old := out[idx].(sdc.Value); supersedes(old, v) {
+				out[idx] = nil
+				if v.GetPrefix() == nil && old.GetPrefix() != nil {
+					// Keep the prefix of the first value sent for the path
+					nv := *v.Value
+					nv.Prefix = old.GetPrefix()
+					v = sdc.Value{Value: &nv}
+				}
+				log.V(6).Infof("Coalesced update for path %v", key)
+			}
+		}
+		pending[key] = len(out)
+		out = append(out, v)
+	}
+
+	items = out[:0]
+	for _, item := range out {
+		if item != nil {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// supersedes tells whether the newer value replaces all data of the older
+// one of the same path. Data clients may send only the changed keys of a JSON
+// object (ex. one port of COUNTERS/Ethernet*), in which case the older value
+// is still needed unless the newer one has all of its keys. Values are never
+// merged, each is sent as the data client made it.
+func supersedes(older, newer sdc.Value) bool {
+	oj := jsonBytes(older.GetVal())
+	if oj == nil {
+		return true
+	}
+	var om, nm map[string]json.RawMessage
+	if err := json.Unmarshal(oj, &om); err != nil {
+		// Not a JSON object
+		return true
+	}
+	if err := json.Unmarshal(jsonBytes(newer.GetVal()), &nm); err != nil {
+		return true
+	}
+	for k := range om {
+		if _, ok := nm[k]; !ok {
+			return false
 		}
-		log.V(5).Infof("Client %s done sending, msg count %d, msg %v", c, c.sendMsg, resp)
 	}
+	return true
+}
+
+// coalescable tells whether pending updates of the subscription list could
+// be coalesced, which is the case if all subscriptions are SAMPLE or
+// ON_CHANGE and only care about the latest value of each path.
+func coalescable(sublist *gnmipb.SubscriptionList) bool {
+	if len(sublist.GetSubscription()) == 0 {
+		return false
+	}
+	for _, sub := range sublist.GetSubscription() {
+		switch sub.GetMode() {
+		case gnmipb.SubscriptionMode_SAMPLE, gnmipb.SubscriptionMode_ON_CHANGE:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func jsonBytes(val *gnmipb.TypedValue) []byte {
+	if j := val.GetJsonIetfVal(); j != nil {
+		return j
+	}
+	return val.GetJsonVal()
 }
@@ -0,0 +1,95 @@
+package gnmi
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"github.com/Azure/sonic-telemetry/metrics"
+)
+
+var (
+	queueDepthDesc = prometheus.NewDesc(
+		"telemetry_gnmi_client_queue_depth",
+		"Number of pending updates in the send queue of a Subscribe client.",
+		[]string{"peer", "target", "mode"}, nil,
+	)
+
+	// Collects queue depth of the clients of the latest created Server
+	clientsCollector = &queueCollector{}
+)
+
+// queueCollector reports queue depth of active subscribe clients at scrape time.
+type queueCollector struct {
+	mu  sync.Mutex
+	srv *Server
+}
+
+func (qc *queueCollector) setServer(srv *Server) {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	qc.srv = srv
+}
+
+// Describe implements prometheus.Collector
+func (qc *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueDepthDesc
+}
+
+// Collect implements prometheus.Collector
+func (qc *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	qc.mu.Lock()
+	srv := qc.srv
+	qc.mu.Unlock()
+	if srv == nil {
+		return
+	}
+
+	srv.cMu.Lock()
+	clients := make([]*Client, 0, len(srv.clients))
+	for _, c := range srv.clients {
+		clients = append(clients, c)
+	}
+	srv.cMu.Unlock()
+
+	for _, c := range clients {
+		st := c.stats()
+		ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue,
+			float64(st.QueueDepth), st.Peer, st.Target, st.Mode)
+	}
+}
+
+// rpcMethod returns the short method name from the full gRPC method name,
+// ex. "Get" from "/gnmi.gNMI/Get"
+func rpcMethod(fullMethod string) string {
+	return fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+}
+
+func observeRpc(fullMethod string, start time.Time, err error) {
+	method := rpcMethod(fullMethod)
+	metrics.RpcRequests.WithLabelValues(method, status.Code(err).String()).Inc()
+	metrics.RpcDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+func unaryMetricsInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	observeRpc(info.FullMethod, start, err)
+	return resp, err
+}
+
+func streamMetricsInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	observeRpc(info.FullMethod, start, err)
+	return err
+}
+
+func init() {
+	prometheus.MustRegister(clientsCollector)
+}
@@ -1,11 +1,13 @@
 package gnmi
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
 	"strings"
 	"sync"
+	"time"
 
 	log "github.com/golang/glog"
 	"golang.org/x/net/context"
@@ -32,6 +34,12 @@ type Server struct {
 	config  *Config
 	cMu     sync.Mutex
 	clients map[string]*Client
+	// Set when the Server is shutting down, new Subscribe is rejected
+	stopping bool
+	// Connections to redis shared by the DB clients
+	conn *sdc.RedisConnManager
+	// conn is created by the Server, to be closed when it stops
+	ownConn bool
 }
 
 // Config is a collection of values for Server
@@ -39,15 +47,22 @@ type Config struct {
 	// Port for the Server to listen on. If 0 or unset the Server will pick a port
 	// for this Server.
 	Port int64
-	RedisLocal bool
 }
 
-// New returns an initialized Server.
-func NewServer(config *Config, opts []grpc.ServerOption, redis_socket bool) (*Server, error) {
+// New returns an initialized Server. DB clients connect to redis with the
+// connection manager, or via unix socket with one closed along with the
+// Server if it is nil.
+func NewServer(config *Config, opts []grpc.ServerOption, conn *sdc.RedisConnManager) (*Server, error) {
 	if config == nil {
 		return nil, errors.New("config not provided")
 	}
+	ownConn := false
+	if conn == nil {
+		conn = sdc.NewRedisConnManager(sdc.RedisConfig{})
+		ownConn = true
+	}
 
+	opts = append(opts, grpc.UnaryInterceptor(unaryMetricsInterceptor), grpc.StreamInterceptor(streamMetricsInterceptor))
 	s := grpc.NewServer(opts...)
 	reflection.Register(s)
 
@@ -55,18 +70,21 @@ func NewServer(config *Config, opts []grpc.ServerOption, redis_socket bool) (*Se
 		s:       s,
 		config:  config,
 		clients: map[string]*Client{},
+		conn:    conn,
+		ownConn: ownConn,
 	}
 	var err error
 	if srv.config.Port < 0 {
 		srv.config.Port = 0
 	}
-	srv.config.RedisLocal = redis_socket
 	// Map the server ip to host any
 	srv.lis, err = net.Listen("tcp", fmt.Sprintf("0.0.0.0:%d", srv.config.Port))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open listener port %d: %v", srv.config.Port, err)
 	}
 	gnmipb.RegisterGNMIServer(srv.s, srv)
+	sdc.SetSessionsGetFunc(srv.sessionsJSON)
+	clientsCollector.setServer(srv)
 	log.V(1).Infof("Created Server on %s", srv.Address())
 	return srv, nil
 }
@@ -80,6 +98,50 @@ func (srv *Server) Serve() error {
 	return srv.s.Serve(srv.lis)
 }
 
+// Stop stops the Server immediately. All listeners and connections are
+// closed and pending RPCs are terminated.
+func (srv *Server) Stop() {
+	srv.cMu.Lock()
+	srv.stopping = true
+	srv.cMu.Unlock()
+	srv.s.Stop()
+	srv.closeConn()
+}
+
+// closeConn closes the redis connections if created by the Server
+func (srv *Server) closeConn() {
+	if srv.ownConn {
+		srv.conn.Close()
+	}
+}
+
+// GracefulStop stops the Server from accepting new connections and RPCs,
+// closes all Subscribe clients with codes.Unavailable and waits for pending
+// RPCs and data client routines to finish. If they are not finished within
+// timeout, the Server is stopped forcibly.
+func (srv *Server) GracefulStop(timeout time.Duration) {
+	srv.cMu.Lock()
+	srv.stopping = true
+	for _, c := range srv.clients {
+		c.shutdown()
+	}
+	srv.cMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		srv.s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		log.V(1).Infof("Server %s stopped gracefully", srv.Address())
+	case <-time.After(timeout):
+		log.V(1).Infof("Server %s graceful stop timed out after %v, stopping", srv.Address(), timeout)
+		srv.s.Stop()
+	}
+	srv.closeConn()
+}
+
 // Address returns the port the Server is listening to.
 func (srv *Server) Address() string {
 	addr := srv.lis.Addr().String()
@@ -91,6 +153,23 @@ func (srv *Server) Port() int64 {
 	return srv.config.Port
 }
 
+// sessionsJSON renders statistics of all active subscribe clients in JSON,
+// keyed by client address.
+func (srv *Server) sessionsJSON() ([]byte, error) {
+	sessions := make(map[string]sessionStats)
+	srv.cMu.Lock()
+	for name, c := range srv.clients {
+		sessions[name] = c.stats()
+	}
+	srv.cMu.Unlock()
+
+	b, err := json.Marshal(sessions)
+	if err != nil {
+		return nil, fmt.Errorf("JSON marshalling error: %v", err)
+	}
+	return b, nil
+}
+
 // Subscribe implements the gNMI Subscribe RPC.
 func (srv *Server) Subscribe(stream gnmipb.GNMI_SubscribeServer) error {
 	ctx := stream.Context()
@@ -114,8 +193,13 @@ func (srv *Server) Subscribe(stream gnmipb.GNMI_SubscribeServer) error {
 	log.V(1).Infof("Inside Subscribe interface")
 
 	c := NewClient(pr.Addr)
+	c.conn = srv.conn
 
 	srv.cMu.Lock()
+	if srv.stopping {
+		srv.cMu.Unlock()
+		return grpc.Errorf(codes.Unavailable, "telemetry server is shutting down")
+	}
 	if oc, ok := srv.clients[c.String()]; ok {
 		log.V(2).Infof("Delete duplicate client %s", oc)
 		oc.Close()
@@ -184,17 +268,18 @@ func (s *Server) Get(ctx context.Context, req *gnmipb.GetRequest) (*gnmipb.GetRe
 		dc, err = sdc.NewNonDbClient(paths, prefix)
 	} else {
 	/* If no prefix target is specified create new Transl Data Client . */
-		dc, err = sdc.NewDbClient(paths, prefix, s.config.RedisLocal)
+		dc, err = sdc.NewDbClient(paths, prefix, s.conn)
 	}
 
 	if err != nil {
 		return nil, status.Error(codes.NotFound, err.Error())
 	}
-	notifications := make([]*gnmipb.Notification, len(paths))
 	spbValues, err := dc.Get(nil)
 	if err != nil {
 		return nil, status.Error(codes.NotFound, err.Error())
 	}
+	// Paths with wildcard may be expanded into more values than paths
+	notifications := make([]*gnmipb.Notification, len(spbValues))
 
 	for index, spbValue := range spbValues {
 		update := &gnmipb.Update{
@@ -220,8 +305,17 @@ func (srv *Server) Set(ctx context.Context,req *gnmipb.SetRequest) (*gnmipb.SetR
 		/* Fetch the prefix. */
 		prefix := req.GetPrefix()
 
-               /* Create Transl client. */
-		dc, _ := sdc.NewTranslClient(prefix, nil)
+		var dc sdc.Client
+		if sdc.IsDbTarget(prefix.GetTarget()) {
+			/* DB targets are validated with table schemas. */
+			dc, err = sdc.NewDbClient(nil, prefix, srv.conn)
+			if err != nil {
+				return nil, status.Error(codes.NotFound, err.Error())
+			}
+		} else {
+			/* Create Transl client. */
+			dc, _ = sdc.NewTranslClient(prefix, nil)
+		}
 
 		/* DELETE */
 		for _, path := range req.GetDelete() {
@@ -309,18 +403,3 @@ func (srv *Server) Capabilities(context.Context, *gnmipb.CapabilityRequest) (*gn
 				 	  SupportedEncodings: supportedEncodings,
 					  GNMIVersion: "0.7.0"}, nil
 }
-
-func  isTargetDb ( target string) (bool) {
-	isDbClient := false 
-	dbTargetSupported := []string { "APPL_DB", "ASIC_DB" , "COUNTERS_DB", "LOGLEVEL_DB", "CONFIG_DB", "PFC_WD_DB", "FLEX_COUNTER_DB", "STATE_DB"}
-
-	    for _, name := range  dbTargetSupported {
-		    if  target ==  name {
-			    isDbClient = true
-				    return isDbClient
-		    }
-	    }
-
-	    return isDbClient
-}
-
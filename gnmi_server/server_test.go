@@ -4,8 +4,10 @@ package gnmi
 // Prerequisite: redis-server should be running.
 
 import (
+	"bytes"
 	"crypto/tls"
 	"encoding/json"
+	"fmt"
 	testcert "github.com/Azure/sonic-telemetry/testdata/tls"
 	"github.com/go-redis/redis"
 	"github.com/golang/protobuf/proto"
@@ -14,21 +16,30 @@ import (
 	"github.com/openconfig/gnmi/client"
 	pb "github.com/openconfig/gnmi/proto/gnmi"
 	"github.com/openconfig/gnmi/value"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/status"
 	"io/ioutil"
+	"net"
 	"os"
 	"os/exec"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 	// Register supported client types.
+	spb "github.com/Azure/sonic-telemetry/proto"
 	sdc "github.com/Azure/sonic-telemetry/sonic_data_client"
 	sdcfg "github.com/Azure/sonic-telemetry/sonic_db_config"
 	gclient "github.com/jipanyang/gnmi/client/gnmi"
+	"github.com/Workiva/go-datastructures/queue"
 
 )
 
@@ -85,7 +96,8 @@ func createServer(t *testing.T) *Server {
 
 	opts := []grpc.ServerOption{grpc.Creds(credentials.NewTLS(tlsCfg))}
 	cfg := &Config{Port: 8081}
-	s, err := NewServer(cfg, opts, true)
+	// Name maps of virtual paths are loaded afresh for each server
+	s, err := NewServer(cfg, opts, sdc.NewRedisConnManager(sdc.RedisConfig{UseTcp: true}))
 	if err != nil {
 		t.Errorf("Failed to create gNMI server: %v", err)
 	}
@@ -210,6 +222,7 @@ func runTestSet(t *testing.T, ctx context.Context, gClient pb.GNMIClient, pathTa
 }
 
 func runServer(t *testing.T, s *Server) {
+	defer s.conn.Close()
 	//t.Log("Starting RPC server on address:", s.Address())
 	err := s.Serve() // blocks until close
 	if err != nil {
@@ -219,14 +232,18 @@ func runServer(t *testing.T, s *Server) {
 }
 
 func getRedisClientN(t *testing.T, n int) *redis.Client {
+	addr, err := sdcfg.GetDbTcpAddr("COUNTERS_DB")
+	if err != nil {
+		t.Fatalf("failed to get redis config %v", err)
+	}
 	rclient := redis.NewClient(&redis.Options{
 		Network:     "tcp",
-		Addr:        sdcfg.GetDbTcpAddr("COUNTERS_DB"),
+		Addr:        addr,
 		Password:    "", // no password set
 		DB:          n,
 		DialTimeout: 0,
 	})
-	_, err := rclient.Ping().Result()
+	_, err = rclient.Ping().Result()
 	if err != nil {
 		t.Fatalf("failed to connect to redis server %v", err)
 	}
@@ -234,14 +251,22 @@ func getRedisClientN(t *testing.T, n int) *redis.Client {
 }
 
 func getRedisClient(t *testing.T) *redis.Client {
+	addr, err := sdcfg.GetDbTcpAddr("COUNTERS_DB")
+	if err != nil {
+		t.Fatalf("failed to get redis config %v", err)
+	}
+	dbn, err := sdcfg.GetDbId("COUNTERS_DB")
+	if err != nil {
+		t.Fatalf("failed to get redis config %v", err)
+	}
 	rclient := redis.NewClient(&redis.Options{
 		Network:     "tcp",
-		Addr:        sdcfg.GetDbTcpAddr("COUNTERS_DB"),
+		Addr:        addr,
 		Password:    "", // no password set
-		DB:          sdcfg.GetDbId("COUNTERS_DB"),
+		DB:          dbn,
 		DialTimeout: 0,
 	})
-	_, err := rclient.Ping().Result()
+	_, err = rclient.Ping().Result()
 	if err != nil {
 		t.Fatalf("failed to connect to redis server %v", err)
 	}
@@ -249,14 +274,22 @@ func getRedisClient(t *testing.T) *redis.Client {
 }
 
 func getConfigDbClient(t *testing.T) *redis.Client {
+	addr, err := sdcfg.GetDbTcpAddr("CONFIG_DB")
+	if err != nil {
+		t.Fatalf("failed to get redis config %v", err)
+	}
+	dbn, err := sdcfg.GetDbId("CONFIG_DB")
+	if err != nil {
+		t.Fatalf("failed to get redis config %v", err)
+	}
 	rclient := redis.NewClient(&redis.Options{
 		Network:     "tcp",
-		Addr:        sdcfg.GetDbTcpAddr("CONFIG_DB"),
+		Addr:        addr,
 		Password:    "", // no password set
-		DB:          sdcfg.GetDbId("CONFIG_DB"),
+		DB:          dbn,
 		DialTimeout: 0,
 	})
-	_, err := rclient.Ping().Result()
+	_, err = rclient.Ping().Result()
 	if err != nil {
 		t.Fatalf("failed to connect to redis server %v", err)
 	}
@@ -1751,18 +1784,367 @@ func TestGnmiSubscribe(t *testing.T) {
 	s.s.Stop()
 }
 
-func TestCapabilities(t *testing.T) {
-	//t.Log("Start server")
+func TestCoalesceItems(t *testing.T) {
+	pathA := &pb.Path{Elem: []*pb.PathElem{{Name: "COUNTERS"}, {Name: "Ethernet*"}}}
+	pathB := &pb.Path{Elem: []*pb.PathElem{{Name: "COUNTERS"}, {Name: "Ethernet68"}, {Name: "Queues"}}}
+	jsonVal := func(s string) *pb.TypedValue {
+		return &pb.TypedValue{Value: &pb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(s)}}
+	}
+	update := func(ts int64, path *pb.Path, val *pb.TypedValue) sdc.Value {
+		return sdc.Value{Value: &spb.Value{Timestamp: ts, Path: path, Val: val}}
+	}
+	sync := func(ts int64) sdc.Value {
+		return sdc.Value{Value: &spb.Value{Timestamp: ts, SyncResponse: true}}
+	}
+	stringVal := func(s string) *pb.TypedValue {
+		return &pb.TypedValue{Value: &pb.TypedValue_StringVal{StringVal: s}}
+	}
+
+	tests := []struct {
+		desc  string
+		items []queue.Item
+		want  []queue.Item
+	}{{
+		desc: "newest scalar value kept at its position",
+		items: []queue.Item{
+			update(1, pathB, stringVal("1")),
+			update(2, pathA, stringVal("a")),
+			update(3, pathB, stringVal("2")),
+		},
+		want: []queue.Item{
+			update(2, pathA, stringVal("a")),
+			update(3, pathB, stringVal("2")),
+		},
+	}, {
+		desc: "newest JSON object kept as it is if it has all keys of the older",
+		items: []queue.Item{
+			update(1, pathA, jsonVal(`{"Ethernet0":{"x":"1","y":"1"},"Ethernet4":{"x":"1"}}`)),
+			update(2, pathA, jsonVal(`{"Ethernet0":{"x":"2"},"Ethernet4":{"x":100000}}`)),
+		},
+		want: []queue.Item{
+			update(2, pathA, jsonVal(`{"Ethernet0":{"x":"2"},"Ethernet4":{"x":100000}}`)),
+		},
+	}, {
+		desc: "partial JSON object not coalesced with older one having other keys",
+		items: []queue.Item{
+			update(1, pathA, jsonVal(`{"Ethernet0":{"x":"1"},"Ethernet4":{"x":"1"}}`)),
+			update(2, pathA, jsonVal(`{"Ethernet0":{"x":"2"}}`)),
+		},
+		want: []queue.Item{
+			update(1, pathA, jsonVal(`{"Ethernet0":{"x":"1"},"Ethernet4":{"x":"1"}}`)),
+			update(2, pathA, jsonVal(`{"Ethernet0":{"x":"2"}}`)),
+		},
+	}, {
+		desc: "no coalescing across sync_response",
+		items: []queue.Item{
+			update(1, pathA, stringVal("1")),
+			sync(2),
+			update(3, pathA, stringVal("2")),
+			update(4, pathA, stringVal("3")),
+		},
+		want: []queue.Item{
+			update(1, pathA, stringVal("1")),
+			sync(2),
+			update(4, pathA, stringVal("3")),
+		},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got := coalesceItems(tt.items)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got: %v,\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoalescable(t *testing.T) {
+	sublist := func(modes ...pb.SubscriptionMode) *pb.SubscriptionList {
+		l := &pb.SubscriptionList{Mode: pb.SubscriptionList_STREAM}
+		for _, mode := range modes {
+			l.Subscription = append(l.Subscription, &pb.Subscription{Mode: mode})
+		}
+		return l
+	}
+	tests := []struct {
+		desc    string
+		sublist *pb.SubscriptionList
+		want    bool
+	}{
+		{"SAMPLE", sublist(pb.SubscriptionMode_SAMPLE), true},
+		{"SAMPLE and ON_CHANGE", sublist(pb.SubscriptionMode_SAMPLE, pb.SubscriptionMode_ON_CHANGE), true},
+		{"TARGET_DEFINED", sublist(pb.SubscriptionMode_TARGET_DEFINED), false},
+		{"ON_CHANGE and TARGET_DEFINED", sublist(pb.SubscriptionMode_ON_CHANGE, pb.SubscriptionMode_TARGET_DEFINED), false},
+		{"no subscription", sublist(), false},
+	}
+	for _, tt := range tests {
+		if got := coalescable(tt.sublist); got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestSessionsJSON(t *testing.T) {
+	s := &Server{clients: map[string]*Client{}}
+	addr := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 50051}
+	c := NewClient(addr)
+	c.subscribe = &pb.SubscriptionList{
+		Prefix: &pb.Path{Target: "COUNTERS_DB"},
+		Mode:   pb.SubscriptionList_STREAM,
+		Subscription: []*pb.Subscription{{
+			Path: &pb.Path{Elem: []*pb.PathElem{{Name: "COUNTERS"}, {Name: "Ethernet68"}}},
+		}},
+	}
+	c.sendMsg = 3
+	c.sendBytes = 300
+	s.clients[c.String()] = c
+
+	b, err := s.sessionsJSON()
+	if err != nil {
+		t.Fatalf("sessionsJSON failed: %v", err)
+	}
+	var got map[string]sessionStats
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("error in unmarshaling sessions %s: %v", b, err)
+	}
+	want := map[string]sessionStats{
+		"10.0.0.1:50051": {
+			Peer:      "10.0.0.1:50051",
+			Target:    "COUNTERS_DB",
+			Mode:      "STREAM",
+			Paths:     []string{"/COUNTERS/Ethernet68"},
+			SendMsg:   3,
+			SendBytes: 300,
+			StartTime: c.start.Format(time.RFC3339),
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v,\nwant %v", got, want)
+	}
+}
+
+func TestGracefulStop(t *testing.T) {
 	s := createServer(t)
 	go runServer(t, s)
 
-	// prepareDb(t)
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
+	targetAddr := "127.0.0.1:8081"
+	conn, err := grpc.Dial(targetAddr, opts...)
+	if err != nil {
+		t.Fatalf("Dialing to %q failed: %v", targetAddr, err)
+	}
+	defer conn.Close()
+
+	gClient := pb.NewGNMIClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := gClient.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	req := &pb.SubscribeRequest{
+		Request: &pb.SubscribeRequest_Subscribe{
+			Subscribe: &pb.SubscriptionList{
+				Prefix: &pb.Path{Target: "OTHERS"},
+				Mode:   pb.SubscriptionList_STREAM,
+				Subscription: []*pb.Subscription{{
+					Path: &pb.Path{Elem: []*pb.PathElem{{Name: "proc"}, {Name: "loadavg"}}},
+				}},
+			},
+		},
+	}
+	if err = stream.Send(req); err != nil {
+		t.Fatalf("Send SubscribeRequest failed: %v", err)
+	}
+	// Wait for sync_response so the client is running
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed before sync: %v", err)
+		}
+		if resp.GetSyncResponse() {
+			break
+		}
+	}
+
+	s.GracefulStop(5 * time.Second)
+	for {
+		_, err = stream.Recv()
+		if err != nil {
+			break
+		}
+	}
+	if code := status.Code(err); code != codes.Unavailable {
+		t.Errorf("got return code %v, want %v: %v", code, codes.Unavailable, err)
+	}
+}
+
+// TestRedisReconnect kills redis connections of stream subscriptions and
+// checks the redis gap notices, resent data and the new sync_response.
+func TestRedisReconnect(t *testing.T) {
+	s := createServer(t)
+	go runServer(t, s)
+	defer s.s.Stop()
+
+	prepareDb(t)
+	rclient := getRedisClient(t)
+	defer rclient.Close()
+	sdc.NotifyRedisGap = true
+	defer func() { sdc.NotifyRedisGap = false }()
 
-	//t.Log("Start gNMI client")
 	tlsConfig := &tls.Config{InsecureSkipVerify: true}
 	opts := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
+	targetAddr := "127.0.0.1:8081"
+	conn, err := grpc.Dial(targetAddr, opts...)
+	if err != nil {
+		t.Fatalf("Dialing to %q failed: %v", targetAddr, err)
+	}
+	defer conn.Close()
 
-	//targetAddr := "30.57.185.38:8080"
+	gClient := pb.NewGNMIClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	stream, err := gClient.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	portPath := &pb.Path{Elem: []*pb.PathElem{{Name: "COUNTERS"}, {Name: "Ethernet68"}}}
+	portsPath := &pb.Path{Elem: []*pb.PathElem{{Name: "COUNTERS"}, {Name: "Ethernet*"}}}
+	req := &pb.SubscribeRequest{
+		Request: &pb.SubscribeRequest_Subscribe{
+			Subscribe: &pb.SubscriptionList{
+				Prefix:       &pb.Path{Target: "COUNTERS_DB"},
+				Mode:         pb.SubscriptionList_STREAM,
+				Subscription: []*pb.Subscription{{Path: portPath}, {Path: portsPath}},
+			},
+		},
+	}
+	if err = stream.Send(req); err != nil {
+		t.Fatalf("Send SubscribeRequest failed: %v", err)
+	}
+	resps := make(chan *pb.SubscribeResponse, 100)
+	go func() {
+		defer close(resps)
+		for {
+			resp, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			resps <- resp
+		}
+	}()
+	// waitSync returns the redis gap notices and updated paths till sync_response
+	waitSync := func() (gaps, updates []string) {
+		for {
+			select {
+			case resp, ok := <-resps:
+				if !ok {
+					t.Fatal("Stream closed before sync_response")
+				}
+				if resp.GetSyncResponse() {
+					return gaps, updates
+				}
+				for _, u := range resp.GetUpdate().GetUpdate() {
+					if proto.Equal(u.GetPath(), sdc.RedisGapPath) {
+						gaps = append(gaps, u.GetVal().GetStringVal())
+						continue
+					}
+					updates = append(updates, fmt.Sprint(u.GetPath().GetElem()))
+				}
+				if len(resp.GetUpdate().GetDelete()) > 0 {
+					t.Errorf("got delete %v, want none", resp.GetUpdate().GetDelete())
+				}
+			case <-ctx.Done():
+				t.Fatal("Timeout waiting for sync_response")
+			}
+		}
+	}
+	waitSync()
+
+	// Pubsub connections killed, the subscriptions are set up again. Normal
+	// connections are killed too, so PING may fail once and back off.
+	for _, kill := range [][]string{{"pubsub"}, {"pubsub", "normal"}} {
+		for _, typ := range kill {
+			cmd := exec.Command("redis-cli", "client", "kill", "type", typ)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("redis-cli client kill type %v failed: %v %s", typ, err, out)
+			}
+		}
+		gaps, updates := waitSync()
+		sort.Strings(gaps)
+		if want := []string{"/COUNTERS/Ethernet*", "/COUNTERS/Ethernet68"}; !reflect.DeepEqual(gaps, want) {
+			t.Errorf("kill %v: got redis gaps %v, want %v", kill, gaps, want)
+		}
+		if len(updates) < 2 {
+			t.Errorf("kill %v: got updates of %v before sync_response, want both paths resent", kill, updates)
+		}
+	}
+
+	// Keyspace notifications are received again. Connection of rclient was
+	// killed too, PING to drop it from the pool.
+	rclient.Ping()
+	if err = rclient.HSet("COUNTERS:oid:0x1000000000039", "SAI_PORT_STAT_PFC_7_RX_PKTS", "99").Err(); err != nil {
+		t.Fatalf("HSet failed: %v", err)
+	}
+	for {
+		select {
+		case resp, ok := <-resps:
+			if !ok {
+				t.Fatal("Stream closed before update")
+			}
+			for _, u := range resp.GetUpdate().GetUpdate() {
+				if bytes.Contains(u.GetVal().GetJsonIetfVal(), []byte(`"SAI_PORT_STAT_PFC_7_RX_PKTS":"99"`)) {
+					return
+				}
+			}
+		case <-ctx.Done():
+			t.Fatal("Timeout waiting for update after resync")
+		}
+	}
+}
+
+func TestVirtualPathConfig(t *testing.T) {
+	cfgFile, err := ioutil.TempFile("", "v2r_config")
+	if err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	defer os.Remove(cfgFile.Name())
+	cfg := `{"mappings": [{
+		"path": ["COUNTERS_DB", "COUNTERS", "Ethernet*", "AllQueues"],
+		"keys": [2],
+		"name_map": "COUNTERS_QUEUE_NAME_MAP",
+		"indexed": true,
+		"alias": "port",
+		"table": "COUNTERS",
+		"json_key": "{alias}:{index}"
+	}]}`
+	cfgFile.WriteString(cfg)
+	cfgFile.Close()
+	if err = sdc.LoadV2rConfig(cfgFile.Name()); err != nil {
+		t.Fatalf("Failed to load virtual path config: %v", err)
+	}
+
+	badFile, err := ioutil.TempFile("", "v2r_config")
+	if err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	defer os.Remove(badFile.Name())
+	badFile.WriteString(`{"mappings": [{"path": ["COUNTERS_DB", "COUNTERS", "Ethernet*"], "keys": [5]}]}`)
+	badFile.Close()
+	if err = sdc.LoadV2rConfig(badFile.Name()); err == nil {
+		t.Errorf("Loading invalid virtual path config succeeded")
+	}
+
+	s := createServer(t)
+	go runServer(t, s)
+	defer s.s.Stop()
+
+	prepareDb(t)
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
 	targetAddr := "127.0.0.1:8081"
 	conn, err := grpc.Dial(targetAddr, opts...)
 	if err != nil {
@@ -1774,18 +2156,1500 @@ func TestCapabilities(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	var req pb.CapabilityRequest
-	resp, err := gClient.Capabilities(ctx, &req)
+	fileName := "../testdata/COUNTERS:Ethernet68:Queues.txt"
+	countersEthernet68QueuesByte, err := ioutil.ReadFile(fileName)
 	if err != nil {
-		t.Fatalf("Failed to get Capabilities")
+		t.Fatalf("read file %v err: %v", fileName, err)
 	}
-	if len(resp.SupportedModels) == 0 {
-		t.Fatalf("No Supported Models found!")
+	textPbPath := `
+		elem: <name: "COUNTERS" >
+		elem: <name: "Ethernet68" >
+		elem: <name: "AllQueues" >
+	`
+	runTestGet(t, ctx, gClient, "COUNTERS_DB", textPbPath, codes.OK, countersEthernet68QueuesByte, true)
+}
+
+func TestDbTargets(t *testing.T) {
+	cfgFile, err := ioutil.TempFile("", "database_config")
+	if err != nil {
+		t.Fatalf("Failed to create database config file: %v", err)
+	}
+	defer os.Remove(cfgFile.Name())
+	cfgFile.WriteString(`{
+		"INSTANCES": {"redis": {"hostname": "127.0.0.1", "port": 6379, "unix_socket_path": "/var/run/redis/redis.sock"}},
+		"DATABASES": {
+			"CONFIG_DB": {"id": 4, "separator": "|", "instance": "redis"},
+			"GB_COUNTERS_DB": {"id": 14, "separator": ":", "instance": "redis"}
+		}
+	}`)
+	cfgFile.Close()
+
+	if errs := sdc.InitDbConfig(cfgFile.Name()); len(errs) != 0 {
+		t.Fatalf("Invalid database config: %v", errs)
+	}
+	defer sdc.InitDbConfig(sdcfg.SONIC_DB_CONFIG_FILE)
+	tests := []struct {
+		target string
+		want   bool
+	}{
+		{"GB_COUNTERS_DB", true},
+		{"localhost/CONFIG_DB", true},
+		{"APPL_DB", false},
+		{"asic0/CONFIG_DB", false},
+		{"OTHERS", false},
+	}
+	for _, tt := range tests {
+		if got := sdc.IsDbTarget(tt.target); got != tt.want {
+			t.Errorf("IsDbTarget(%v) = %v, want %v", tt.target, got, tt.want)
+		}
+	}
+	// DB number is the id in config
+	if id := spb.Target_value["GB_COUNTERS_DB"]; id != 14 {
+		t.Errorf("got id %v of GB_COUNTERS_DB, want 14", id)
+	}
+	conn := sdc.NewRedisConnManager(sdc.RedisConfig{})
+	defer conn.Close()
+	redisDb, err := conn.Client("", "GB_COUNTERS_DB")
+	if err != nil {
+		t.Fatalf("Failed to get client of GB_COUNTERS_DB: %v", err)
+	}
+	if dbn := redisDb.Options().DB; dbn != 14 {
+		t.Errorf("got DB number %v of GB_COUNTERS_DB, want 14", dbn)
+	}
+}
+
+func TestRedisConnManager(t *testing.T) {
+	conn := sdc.NewRedisConnManager(sdc.RedisConfig{UseTcp: true, PoolSize: 2, DialTimeout: time.Second})
+	defer conn.Close()
+
+	if _, err := conn.Client("", "UNKNOWN_DB"); err == nil {
+		t.Errorf("got client of unknown DB")
+	}
+	if _, err := conn.Client("asic9", "CONFIG_DB"); err == nil {
+		t.Errorf("got client of unknown namespace")
+	}
+	// Clients are shared until closed
+	c1, err := conn.Client("", "CONFIG_DB")
+	if err != nil {
+		t.Fatalf("Failed to get client of CONFIG_DB: %v", err)
+	}
+	if c2, _ := conn.Client("", "CONFIG_DB"); c2 != c1 {
+		t.Errorf("got another client of CONFIG_DB")
+	}
+	if opts := c1.Options(); opts.Network != "tcp" || opts.PoolSize != 2 || opts.DialTimeout != time.Second {
+		t.Errorf("got client options %+v", opts)
+	}
+	conn.Close()
+	if c3, _ := conn.Client("", "CONFIG_DB"); c3 == c1 {
+		t.Errorf("got closed client of CONFIG_DB")
+	}
+}
+
+// TestDbClientConnManager checks DB clients of different connection managers
+// don't share the virtual path name maps, and nil manager is the default one.
+func TestDbClientConnManager(t *testing.T) {
+	prepareDb(t)
+	rclient := getRedisClient(t)
+	defer rclient.Close()
+
+	prefix := &pb.Path{Target: "COUNTERS_DB"}
+	portPath := func(name string) []*pb.Path {
+		return []*pb.Path{{Elem: []*pb.PathElem{{Name: "COUNTERS"}, {Name: name}}}}
+	}
+	if _, err := sdc.NewDbClient(portPath("Ethernet68"), prefix, nil); err != nil {
+		t.Errorf("NewDbClient with nil connection manager failed: %v", err)
+	}
+	connA := sdc.NewRedisConnManager(sdc.RedisConfig{})
+	defer connA.Close()
+	if _, err := sdc.NewDbClient(portPath("Ethernet68"), prefix, connA); err != nil {
+		t.Fatalf("NewDbClient failed: %v", err)
+	}
+
+	// Port added after name maps of connA are loaded is found by clients of
+	// a new manager right away, without waiting for the maps of connA.
+	if err := rclient.HSet("COUNTERS_PORT_NAME_MAP", "Ethernet200", "oid:0x1000000000003").Err(); err != nil {
+		t.Fatalf("HSet failed: %v", err)
+	}
+	connB := sdc.NewRedisConnManager(sdc.RedisConfig{})
+	defer connB.Close()
+	if _, err := sdc.NewDbClient(portPath("Ethernet200"), prefix, connB); err != nil {
+		t.Errorf("NewDbClient of new port failed: %v", err)
 	}
 
+	// Name maps are loaded again after the manager is closed
+	connA.Close()
+	if _, err := sdc.NewDbClient(portPath("Ethernet200"), prefix, connA); err != nil {
+		t.Errorf("NewDbClient of new port after Close failed: %v", err)
+	}
 }
 
-func init() {
-	// Inform gNMI server to use redis tcp localhost connection
-	sdc.UseRedisLocalTcpPort = true
+// TestVirtualPathNameMapsChange adds a port to and removes it from the port
+// name map, stream subscription on virtual path follows the change.
+func TestVirtualPathNameMapsChange(t *testing.T) {
+	s := createServer(t)
+	go runServer(t, s)
+	defer s.s.Stop()
+
+	prepareDb(t)
+	rclient := getRedisClient(t)
+	defer rclient.Close()
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
+	targetAddr := "127.0.0.1:8081"
+	conn, err := grpc.Dial(targetAddr, opts...)
+	if err != nil {
+		t.Fatalf("Dialing to %q failed: %v", targetAddr, err)
+	}
+	defer conn.Close()
+
+	gClient := pb.NewGNMIClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	stream, err := gClient.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	portsPath := &pb.Path{Elem: []*pb.PathElem{{Name: "COUNTERS"}, {Name: "Ethernet*"}}}
+	req := &pb.SubscribeRequest{
+		Request: &pb.SubscribeRequest_Subscribe{
+			Subscribe: &pb.SubscriptionList{
+				Prefix:       &pb.Path{Target: "COUNTERS_DB"},
+				Mode:         pb.SubscriptionList_STREAM,
+				Subscription: []*pb.Subscription{{Path: portsPath}},
+			},
+		},
+	}
+	if err = stream.Send(req); err != nil {
+		t.Fatalf("Send SubscribeRequest failed: %v", err)
+	}
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed before sync: %v", err)
+		}
+		if resp.GetSyncResponse() {
+			break
+		}
+	}
+
+	newPort := "Ethernet200"
+	if err = rclient.HSet("COUNTERS_PORT_NAME_MAP", newPort, "oid:0x1000000000003").Err(); err != nil {
+		t.Fatalf("HSet failed: %v", err)
+	}
+	for added := false; !added; {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed before %v added: %v", newPort, err)
+		}
+		for _, u := range resp.GetUpdate().GetUpdate() {
+			var v map[string]interface{}
+			json.Unmarshal(u.GetVal().GetJsonIetfVal(), &v)
+			_, added = v[newPort]
+		}
+		if len(resp.GetUpdate().GetDelete()) > 0 {
+			t.Fatalf("got delete %v before %v added", resp.GetUpdate().GetDelete(), newPort)
+		}
+	}
+
+	if err = rclient.HDel("COUNTERS_PORT_NAME_MAP", newPort).Err(); err != nil {
+		t.Fatalf("HDel failed: %v", err)
+	}
+	wantDel := &pb.Path{Elem: []*pb.PathElem{{Name: "COUNTERS"}, {Name: "Ethernet*"}, {Name: newPort}}}
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed before %v deleted: %v", newPort, err)
+		}
+		dels := resp.GetUpdate().GetDelete()
+		if len(dels) == 0 {
+			continue
+		}
+		if len(dels) != 1 || !proto.Equal(dels[0], wantDel) {
+			t.Fatalf("got delete %v, want %v", dels, wantDel)
+		}
+		break
+	}
 }
+
+func TestTableSchema(t *testing.T) {
+	cfgFile, err := ioutil.TempFile("", "table_schema")
+	if err != nil {
+		t.Fatalf("Failed to create schema file: %v", err)
+	}
+	defer os.Remove(cfgFile.Name())
+	cfg := `{"tables": {"CONFIG_DB": {
+		"PORT": {"keys": ["name"], "fields": {"alias": "string", "speed": "uint", "lanes": "list", "weight": "float"}}
+	}}}`
+	cfgFile.WriteString(cfg)
+	cfgFile.Close()
+	if err = sdc.LoadTableSchema(cfgFile.Name()); err != nil {
+		t.Fatalf("Failed to load table schema: %v", err)
+	}
+
+	badFile, err := ioutil.TempFile("", "table_schema")
+	if err != nil {
+		t.Fatalf("Failed to create schema file: %v", err)
+	}
+	defer os.Remove(badFile.Name())
+	badFile.WriteString(`{"tables": {"CONFIG_DB": {"PORT": {"fields": {"speed": "integer"}}}}}`)
+	badFile.Close()
+	if err = sdc.LoadTableSchema(badFile.Name()); err == nil {
+		t.Errorf("Loading invalid table schema succeeded")
+	}
+
+	s := createServer(t)
+	go runServer(t, s)
+	defer s.s.Stop()
+
+	prepareDb(t)
+	configDb := getConfigDbClient(t)
+	defer configDb.Close()
+	loadConfigDB(t, configDb, map[string]interface{}{
+		"PORT|Ethernet68":          map[string]interface{}{"speed": "100000", "lanes": "65,66,67,68", "weight": "0.1234567891"},
+		"BUFFER_PG|Ethernet68|3-4": map[string]interface{}{"profile": "[BUFFER_PROFILE|pg_lossless_100000_5m_profile]"},
+	})
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
+	targetAddr := "127.0.0.1:8081"
+	conn, err := grpc.Dial(targetAddr, opts...)
+	if err != nil {
+		t.Fatalf("Dialing to %q failed: %v", targetAddr, err)
+	}
+	defer conn.Close()
+
+	gClient := pb.NewGNMIClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tds := []struct {
+		desc        string
+		textPbPath  string
+		wantRetCode codes.Code
+		wantRespVal interface{}
+	}{{
+		desc: "get PORT Ethernet68 rendered by field types",
+		textPbPath: `
+			elem: <name: "PORT" >
+			elem: <name: "Ethernet68" >
+		`,
+		wantRetCode: codes.OK,
+		wantRespVal: []byte(`{"alias": "Ethernet68/1", "speed": 100000, "lanes": ["65", "66", "67", "68"], "weight": 0.1234567891}`),
+	}, {
+		desc: "get PORT Ethernet68 speed of uint type",
+		textPbPath: `
+			elem: <name: "PORT" >
+			elem: <name: "Ethernet68" >
+			elem: <name: "speed" >
+		`,
+		wantRetCode: codes.OK,
+		wantRespVal: uint64(100000),
+	}, {
+		desc: "get PORT Ethernet68 weight of float type as it is",
+		textPbPath: `
+			elem: <name: "PORT" >
+			elem: <name: "Ethernet68" >
+			elem: <name: "weight" >
+		`,
+		wantRetCode: codes.OK,
+		wantRespVal: "0.1234567891",
+	}, {
+		desc: "get BUFFER_PG with key parts by name",
+		textPbPath: `
+			elem: <name: "BUFFER_PG" key: <key: "port" value: "Ethernet68" > key: <key: "pg" value: "3-4" > >
+			elem: <name: "profile" >
+		`,
+		wantRetCode: codes.OK,
+		wantRespVal: "[BUFFER_PROFILE|pg_lossless_100000_5m_profile]",
+	}, {
+		desc: "get BUFFER_PG with too many elements",
+		textPbPath: `
+			elem: <name: "BUFFER_PG" >
+			elem: <name: "Ethernet68" >
+			elem: <name: "3-4" >
+			elem: <name: "profile" >
+			elem: <name: "extra" >
+		`,
+		wantRetCode: codes.NotFound,
+	}}
+
+	for _, td := range tds {
+		t.Run(td.desc, func(t *testing.T) {
+			runTestGet(t, ctx, gClient, "CONFIG_DB", td.textPbPath, td.wantRetCode, td.wantRespVal, true)
+		})
+	}
+
+	prefix := &pb.Path{Target: "CONFIG_DB"}
+	dc, err := sdc.NewDbClient(nil, prefix, testRedisConn)
+	if err != nil {
+		t.Fatalf("Failed to create db client: %v", err)
+	}
+	sets := []struct {
+		desc    string
+		path    string
+		val     *pb.TypedValue
+		wantErr bool
+	}{
+		{"set speed of uint", "PORT/Ethernet68/speed", &pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: 40000}}, false},
+		{"set speed of invalid value", "PORT/Ethernet68/speed", &pb.TypedValue{Value: &pb.TypedValue_StringVal{StringVal: "fast"}}, true},
+		{"set unknown field", "PORT/Ethernet68/no_such_field", &pb.TypedValue{Value: &pb.TypedValue_StringVal{StringVal: "1"}}, true},
+		{"set port in JSON", "PORT/Ethernet68", &pb.TypedValue{Value: &pb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{"speed": "40000"}`)}}, false},
+		{"set port speed of JSON number", "PORT/Ethernet68", &pb.TypedValue{Value: &pb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{"speed": 100000}`)}}, false},
+		{"set port speed of JSON fraction", "PORT/Ethernet68", &pb.TypedValue{Value: &pb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{"speed": 1.5}`)}}, true},
+		{"set missing key part", "BUFFER_PG/Ethernet68", &pb.TypedValue{Value: &pb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{}`)}}, true},
+	}
+	for _, set := range sets {
+		var path pb.Path
+		for _, name := range strings.Split(set.path, "/") {
+			path.Elem = append(path.Elem, &pb.PathElem{Name: name})
+		}
+		err := dc.Set(&path, set.val, sdc.UPDATE)
+		if (err != nil) != set.wantErr {
+			t.Errorf("%v: got error %v, want error %v", set.desc, err, set.wantErr)
+		}
+		// Set RPC of DB target is validated by the DB client too
+		_, err = gClient.Set(ctx, &pb.SetRequest{
+			Prefix: prefix,
+			Update: []*pb.Update{{Path: &path, Val: set.val}},
+		})
+		if (err != nil) != set.wantErr {
+			t.Errorf("%v via Set RPC: got error %v, want error %v", set.desc, err, set.wantErr)
+		}
+	}
+}
+
+// virtualPathGetTest is a Get test of virtual path in COUNTERS_DB
+type virtualPathGetTest struct {
+	desc        string
+	textPbPath  string
+	wantRetCode codes.Code
+	wantRespVal interface{}
+}
+
+// startVirtualPathServer loads COUNTERS_DB and CONFIG_DB fixtures over
+// prepareDb, then starts the server and dials it. The server has its own
+// connection manager, which loads the name maps of virtual paths from the
+// fixtures upon the first request, so there is no wait for them to be
+// refreshed. The returned func stops the server and closes the connection.
+func startVirtualPathServer(t *testing.T, counters, config map[string]interface{}) (pb.GNMIClient, func()) {
+	prepareDb(t)
+	rclient := getRedisClient(t)
+	defer rclient.Close()
+	loadDB(t, rclient, counters)
+	configDb := getConfigDbClient(t)
+	defer configDb.Close()
+	loadConfigDB(t, configDb, config)
+
+	s := createServer(t)
+	go runServer(t, s)
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
+	targetAddr := "127.0.0.1:8081"
+	conn, err := grpc.Dial(targetAddr, opts...)
+	if err != nil {
+		s.s.Stop()
+		t.Fatalf("Dialing to %q failed: %v", targetAddr, err)
+	}
+	return pb.NewGNMIClient(conn), func() {
+		conn.Close()
+		s.s.Stop()
+	}
+}
+
+// runVirtualPathGets runs the Get tests, values are checked for codes.OK
+func runVirtualPathGets(t *testing.T, gClient pb.GNMIClient, tds []virtualPathGetTest) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, td := range tds {
+		t.Run(td.desc, func(t *testing.T) {
+			runTestGet(t, ctx, gClient, "COUNTERS_DB", td.textPbPath, td.wantRetCode, td.wantRespVal, td.wantRetCode == codes.OK)
+		})
+	}
+}
+
+func TestVirtualPathRif(t *testing.T) {
+	rifNameMap := map[string]interface{}{
+		"Vlan1000":        "oid:0x6000000000b01",
+		"PortChannel0001": "oid:0x6000000000b02",
+		"Ethernet68":      "oid:0x6000000000b03",
+	}
+	counters := map[string]interface{}{"COUNTERS_RIF_NAME_MAP": rifNameMap}
+	for name, oid := range rifNameMap {
+		counters["COUNTERS:"+oid.(string)] = map[string]interface{}{
+			"SAI_ROUTER_INTERFACE_STAT_IN_ERROR_PACKETS": "1",
+			"SAI_ROUTER_INTERFACE_STAT_IN_PACKETS":       name,
+		}
+	}
+	gClient, stop := startVirtualPathServer(t, counters, nil)
+	defer stop()
+
+	runVirtualPathGets(t, gClient, []virtualPathGetTest{{
+		desc: "get COUNTERS:Vlan1000",
+		textPbPath: `
+			elem: <name: "COUNTERS" >
+			elem: <name: "Vlan1000" >
+		`,
+		wantRetCode: codes.OK,
+		wantRespVal: []byte(`{"SAI_ROUTER_INTERFACE_STAT_IN_ERROR_PACKETS": "1", "SAI_ROUTER_INTERFACE_STAT_IN_PACKETS": "Vlan1000"}`),
+	}, {
+		desc: "get COUNTERS:Vlan*",
+		textPbPath: `
+			elem: <name: "COUNTERS" >
+			elem: <name: "Vlan*" >
+		`,
+		wantRetCode: codes.OK,
+		wantRespVal: []byte(`{"Vlan1000": {"SAI_ROUTER_INTERFACE_STAT_IN_ERROR_PACKETS": "1", "SAI_ROUTER_INTERFACE_STAT_IN_PACKETS": "Vlan1000"}}`),
+	}, {
+		desc: "get COUNTERS:PortChannel* SAI_ROUTER_INTERFACE_STAT_IN_PACKETS",
+		textPbPath: `
+			elem: <name: "COUNTERS" >
+			elem: <name: "PortChannel*" >
+			elem: <name: "SAI_ROUTER_INTERFACE_STAT_IN_PACKETS" >
+		`,
+		wantRetCode: codes.OK,
+		wantRespVal: []byte(`{"PortChannel0001": {"SAI_ROUTER_INTERFACE_STAT_IN_PACKETS": "PortChannel0001"}}`),
+	}, {
+		desc: "get COUNTERS (use vendor alias):Ethernet68/1 Rif SAI_ROUTER_INTERFACE_STAT_IN_PACKETS",
+		textPbPath: `
+			elem: <name: "COUNTERS" >
+			elem: <name: "Ethernet68/1" >
+			elem: <name: "Rif" >
+			elem: <name: "SAI_ROUTER_INTERFACE_STAT_IN_PACKETS" >
+		`,
+		wantRetCode: codes.OK,
+		wantRespVal: "Ethernet68",
+	}, {
+		desc: "get non-existing COUNTERS:Vlan2000",
+		textPbPath: `
+			elem: <name: "COUNTERS" >
+			elem: <name: "Vlan2000" >
+		`,
+		wantRetCode: codes.NotFound,
+	}})
+}
+
+func TestVirtualPathBuffer(t *testing.T) {
+	gClient, stop := startVirtualPathServer(t, map[string]interface{}{
+		"COUNTERS_PG_NAME_MAP": map[string]interface{}{
+			"Ethernet68:3": "oid:0x1a00000000015d",
+			"Ethernet1:3":  "oid:0x1a00000000016d",
+		},
+		"COUNTERS_BUFFER_POOL_NAME_MAP": map[string]interface{}{
+			"ingress_lossless_pool": "oid:0x18000000000a6e",
+		},
+		"USER_WATERMARKS:oid:0x1a00000000015d": map[string]interface{}{
+			"SAI_INGRESS_PRIORITY_GROUP_STAT_SHARED_WATERMARK_BYTES": "1024",
+		},
+		"COUNTERS:oid:0x1a00000000015d": map[string]interface{}{
+			"SAI_INGRESS_PRIORITY_GROUP_STAT_PACKETS": "10",
+		},
+		"USER_WATERMARKS:oid:0x18000000000a6e": map[string]interface{}{
+			"SAI_BUFFER_POOL_STAT_WATERMARK_BYTES": "2048",
+		},
+	}, nil)
+	defer stop()
+
+	runVirtualPathGets(t, gClient, []virtualPathGetTest{{
+		desc: "get COUNTERS:Ethernet68 PriorityGroups",
+		textPbPath: `
+			elem: <name: "COUNTERS" >
+			elem: <name: "Ethernet68" >
+			elem: <name: "PriorityGroups" >
+		`,
+		wantRespVal: []byte(`{"Ethernet68:3": {"SAI_INGRESS_PRIORITY_GROUP_STAT_PACKETS": "10"}}`),
+	}, {
+		desc: "get COUNTERS (use vendor alias):Ethernet68/1 Watermarks",
+		textPbPath: `
+			elem: <name: "COUNTERS" >
+			elem: <name: "Ethernet68/1" >
+			elem: <name: "Watermarks" >
+		`,
+		wantRespVal: []byte(`{"Ethernet68/1:3": {"SAI_INGRESS_PRIORITY_GROUP_STAT_SHARED_WATERMARK_BYTES": "1024"}}`),
+	}, {
+		desc: "get COUNTERS:BufferPools * Watermarks",
+		textPbPath: `
+			elem: <name: "COUNTERS" >
+			elem: <name: "BufferPools" >
+			elem: <name: "*" >
+			elem: <name: "Watermarks" >
+		`,
+		wantRespVal: []byte(`{"ingress_lossless_pool": {"SAI_BUFFER_POOL_STAT_WATERMARK_BYTES": "2048"}}`),
+	}})
+}
+
+func TestVirtualPathAcl(t *testing.T) {
+	gClient, stop := startVirtualPathServer(t, map[string]interface{}{
+		"ACL_COUNTER_RULE_MAP": map[string]interface{}{
+			"DATAACL:RULE_1":  "oid:0x9000000000601",
+			"DATAACL:RULE_2":  "oid:0x9000000000602",
+			"EVERFLOW:RULE_1": "oid:0x9000000000603",
+		},
+		"COUNTERS:oid:0x9000000000601": map[string]interface{}{
+			"SAI_ACL_COUNTER_ATTR_PACKETS": "1",
+			"SAI_ACL_COUNTER_ATTR_BYTES":   "100",
+		},
+		"COUNTERS:oid:0x9000000000602": map[string]interface{}{
+			"SAI_ACL_COUNTER_ATTR_PACKETS": "2",
+			"SAI_ACL_COUNTER_ATTR_BYTES":   "200",
+		},
+		"COUNTERS:oid:0x9000000000603": map[string]interface{}{
+			"SAI_ACL_COUNTER_ATTR_PACKETS": "3",
+			"SAI_ACL_COUNTER_ATTR_BYTES":   "300",
+		},
+	}, nil)
+	defer stop()
+
+	runVirtualPathGets(t, gClient, []virtualPathGetTest{{
+		desc: "get ACL DATAACL RULE_1",
+		textPbPath: `
+			elem: <name: "ACL" >
+			elem: <name: "DATAACL" >
+			elem: <name: "RULE_1" >
+		`,
+		wantRespVal: []byte(`{"SAI_ACL_COUNTER_ATTR_PACKETS": "1", "SAI_ACL_COUNTER_ATTR_BYTES": "100"}`),
+	}, {
+		desc: "get ACL DATAACL *",
+		textPbPath: `
+			elem: <name: "ACL" >
+			elem: <name: "DATAACL" >
+			elem: <name: "*" >
+		`,
+		wantRespVal: []byte(`{"DATAACL:RULE_1": {"SAI_ACL_COUNTER_ATTR_PACKETS": "1", "SAI_ACL_COUNTER_ATTR_BYTES": "100"},
+			"DATAACL:RULE_2": {"SAI_ACL_COUNTER_ATTR_PACKETS": "2", "SAI_ACL_COUNTER_ATTR_BYTES": "200"}}`),
+	}, {
+		desc: "get ACL * RULE_1 SAI_ACL_COUNTER_ATTR_PACKETS",
+		textPbPath: `
+			elem: <name: "ACL" >
+			elem: <name: "*" >
+			elem: <name: "RULE_1" >
+			elem: <name: "SAI_ACL_COUNTER_ATTR_PACKETS" >
+		`,
+		wantRespVal: []byte(`{"DATAACL:RULE_1": {"SAI_ACL_COUNTER_ATTR_PACKETS": "1"},
+			"EVERFLOW:RULE_1": {"SAI_ACL_COUNTER_ATTR_PACKETS": "3"}}`),
+	}})
+}
+
+func TestGnmiGetMultiPartKey(t *testing.T) {
+	s := createServer(t)
+	go runServer(t, s)
+	defer s.s.Stop()
+
+	prepareDb(t)
+	configDb := getConfigDbClient(t)
+	defer configDb.Close()
+	loadConfigDB(t, configDb, map[string]interface{}{
+		"BUFFER_PG|Ethernet68|3-4":               map[string]interface{}{"profile": "[BUFFER_PROFILE|pg_lossless_100000_5m_profile]"},
+		"VLAN_INTERFACE|Vlan1000|192.168.0.1/21": map[string]interface{}{"NULL": "NULL"},
+	})
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
+	targetAddr := "127.0.0.1:8081"
+	conn, err := grpc.Dial(targetAddr, opts...)
+	if err != nil {
+		t.Fatalf("Dialing to %q failed: %v", targetAddr, err)
+	}
+	defer conn.Close()
+
+	gClient := pb.NewGNMIClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tds := []struct {
+		desc        string
+		textPbPath  string
+		wantRetCode codes.Code
+		wantRespVal interface{}
+	}{{
+		desc: "get BUFFER_PG Ethernet68 3-4",
+		textPbPath: `
+			elem: <name: "BUFFER_PG" >
+			elem: <name: "Ethernet68" >
+			elem: <name: "3-4" >
+		`,
+		wantRetCode: codes.OK,
+		wantRespVal: []byte(`{"profile": "[BUFFER_PROFILE|pg_lossless_100000_5m_profile]"}`),
+	}, {
+		desc: "get BUFFER_PG Ethernet68 3-4 profile",
+		textPbPath: `
+			elem: <name: "BUFFER_PG" >
+			elem: <name: "Ethernet68" >
+			elem: <name: "3-4" >
+			elem: <name: "profile" >
+		`,
+		wantRetCode: codes.OK,
+		wantRespVal: "[BUFFER_PROFILE|pg_lossless_100000_5m_profile]",
+	}, {
+		desc: "get VLAN_INTERFACE with explicit key",
+		textPbPath: `
+			elem: <name: "VLAN_INTERFACE" key: <key: "key" value: "Vlan1000|192.168.0.1/21" > >
+			elem: <name: "NULL" >
+		`,
+		wantRetCode: codes.OK,
+		wantRespVal: "NULL",
+	}, {
+		desc: "get non-existing BUFFER_PG Ethernet68 0 profile",
+		textPbPath: `
+			elem: <name: "BUFFER_PG" >
+			elem: <name: "Ethernet68" >
+			elem: <name: "0" >
+			elem: <name: "profile" >
+		`,
+		wantRetCode: codes.NotFound,
+	}}
+
+	for _, td := range tds {
+		t.Run(td.desc, func(t *testing.T) {
+			runTestGet(t, ctx, gClient, "CONFIG_DB", td.textPbPath, td.wantRetCode, td.wantRespVal, true)
+		})
+	}
+}
+
+func TestGnmiGetWildcard(t *testing.T) {
+	s := createServer(t)
+	go runServer(t, s)
+	defer s.s.Stop()
+
+	prepareDb(t)
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
+	targetAddr := "127.0.0.1:8081"
+	conn, err := grpc.Dial(targetAddr, opts...)
+	if err != nil {
+		t.Fatalf("Dialing to %q failed: %v", targetAddr, err)
+	}
+	defer conn.Close()
+
+	gClient := pb.NewGNMIClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tds := []struct {
+		desc        string
+		textPbPath  string
+		wantRetCode codes.Code
+		wantCount   int
+		wantPath    string
+		wantVal     string
+	}{{
+		desc: "get CONFIG_DB PORT * alias",
+		textPbPath: `
+			elem: <name: "PORT" >
+			elem: <name: "*" >
+			elem: <name: "alias" >
+		`,
+		wantRetCode: codes.OK,
+		wantCount:   54,
+		wantPath:    "PORT/Ethernet68/alias",
+		wantVal:     "Ethernet68/1",
+	}, {
+		desc: "get CONFIG_DB ... alias",
+		textPbPath: `
+			elem: <name: "..." >
+			elem: <name: "alias" >
+		`,
+		wantRetCode: codes.OK,
+		wantCount:   54,
+		wantPath:    "PORT/Ethernet1/alias",
+		wantVal:     "Ethernet1/1",
+	}, {
+		desc: "get CONFIG_DB PORT Ethernet68 *",
+		textPbPath: `
+			elem: <name: "PORT" >
+			elem: <name: "Ethernet68" >
+			elem: <name: "*" >
+		`,
+		wantRetCode: codes.OK,
+		wantCount:   1,
+		wantPath:    "PORT/Ethernet68/alias",
+		wantVal:     "Ethernet68/1",
+	}, {
+		desc: "get CONFIG_DB PORT * non-existing field",
+		textPbPath: `
+			elem: <name: "PORT" >
+			elem: <name: "*" >
+			elem: <name: "no_such_field" >
+		`,
+		wantRetCode: codes.NotFound,
+	}}
+
+	for _, td := range tds {
+		t.Run(td.desc, func(t *testing.T) {
+			var pbPath pb.Path
+			if err := proto.UnmarshalText(td.textPbPath, &pbPath); err != nil {
+				t.Fatalf("error in unmarshaling path: %v %v", td.textPbPath, err)
+			}
+			resp, err := gClient.Get(ctx, &pb.GetRequest{
+				Prefix:   &pb.Path{Target: "CONFIG_DB"},
+				Path:     []*pb.Path{&pbPath},
+				Encoding: pb.Encoding_JSON_IETF,
+			})
+			if status.Code(err) != td.wantRetCode {
+				t.Fatalf("got return code %v, want %v: %v", status.Code(err), td.wantRetCode, err)
+			}
+			if err != nil {
+				return
+			}
+			notifs := resp.GetNotification()
+			if len(notifs) != td.wantCount {
+				t.Errorf("got %d notifications, want %d", len(notifs), td.wantCount)
+			}
+			var found bool
+			for _, notif := range notifs {
+				update := notif.GetUpdate()[0]
+				var names []string
+				for _, elem := range update.GetPath().GetElem() {
+					names = append(names, elem.GetName())
+				}
+				if strings.Join(names, "/") == td.wantPath {
+					found = true
+					if got := update.GetVal().GetStringVal(); got != td.wantVal {
+						t.Errorf("got %v for %v, want %v", got, td.wantPath, td.wantVal)
+					}
+				}
+			}
+			if !found {
+				t.Errorf("%v not found in response", td.wantPath)
+			}
+		})
+	}
+
+	// Expansion beyond the limit fails
+	sdc.WildcardLimit = 10
+	defer func() { sdc.WildcardLimit = 10000 }()
+	runTestGet(t, ctx, gClient, "CONFIG_DB", `elem: <name: "PORT" > elem: <name: "*" > elem: <name: "alias" >`, codes.NotFound, nil, false)
+}
+
+func TestGnmiSubscribeWildcard(t *testing.T) {
+	s := createServer(t)
+	go runServer(t, s)
+	defer s.s.Stop()
+
+	prepareDb(t)
+	rclient := getConfigDbClient(t)
+	defer rclient.Close()
+	rclient.HSet("WILDCARD_TEST|a", "f", "1")
+	defer rclient.Del("WILDCARD_TEST|a", "WILDCARD_TEST|b")
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
+	targetAddr := "127.0.0.1:8081"
+	conn, err := grpc.Dial(targetAddr, opts...)
+	if err != nil {
+		t.Fatalf("Dialing to %q failed: %v", targetAddr, err)
+	}
+	defer conn.Close()
+
+	gClient := pb.NewGNMIClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var pbPath pb.Path
+	proto.UnmarshalText(`elem: <name: "WILDCARD_TEST" > elem: <name: "*" > elem: <name: "f" >`, &pbPath)
+	subscribe := func(mode pb.SubscriptionList_Mode) pb.GNMI_SubscribeClient {
+		stream, err := gClient.Subscribe(ctx)
+		if err != nil {
+			t.Fatalf("Subscribe failed: %v", err)
+		}
+		err = stream.Send(&pb.SubscribeRequest{
+			Request: &pb.SubscribeRequest_Subscribe{
+				Subscribe: &pb.SubscriptionList{
+					Prefix:       &pb.Path{Target: "CONFIG_DB"},
+					Mode:         mode,
+					Subscription: []*pb.Subscription{{Path: &pbPath}},
+				},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Send SubscribeRequest failed: %v", err)
+		}
+		return stream
+	}
+	// Number of updates until sync_response
+	recvUpdates := func(stream pb.GNMI_SubscribeClient) int {
+		var n int
+		for {
+			resp, err := stream.Recv()
+			if err != nil {
+				t.Fatalf("Recv failed: %v", err)
+			}
+			if resp.GetSyncResponse() {
+				return n
+			}
+			n += len(resp.GetUpdate().GetUpdate())
+		}
+	}
+
+	t.Run("STREAM mode is rejected", func(t *testing.T) {
+		stream := subscribe(pb.SubscriptionList_STREAM)
+		if resp, err := stream.Recv(); err == nil {
+			t.Errorf("got %v, want error", resp)
+		}
+	})
+
+	t.Run("POLL mode expands keys added later", func(t *testing.T) {
+		stream := subscribe(pb.SubscriptionList_POLL)
+		defer stream.CloseSend()
+		if n := recvUpdates(stream); n != 1 {
+			t.Fatalf("got %d updates, want 1", n)
+		}
+		rclient.HSet("WILDCARD_TEST|b", "f", "2")
+		if err := stream.Send(&pb.SubscribeRequest{Request: &pb.SubscribeRequest_Poll{Poll: &pb.Poll{}}}); err != nil {
+			t.Fatalf("Send Poll failed: %v", err)
+		}
+		if n := recvUpdates(stream); n != 2 {
+			t.Errorf("got %d updates, want 2", n)
+		}
+	})
+}
+
+func TestGnmiGetNamespace(t *testing.T) {
+	s := createServer(t)
+	go runServer(t, s)
+	defer s.s.Stop()
+
+	prepareDb(t)
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
+	targetAddr := "127.0.0.1:8081"
+	conn, err := grpc.Dial(targetAddr, opts...)
+	if err != nil {
+		t.Fatalf("Dialing to %q failed: %v", targetAddr, err)
+	}
+	defer conn.Close()
+
+	gClient := pb.NewGNMIClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Only the default namespace is available in test
+	tds := []struct {
+		desc        string
+		pathTarget  string
+		textPbPath  string
+		wantRetCode codes.Code
+		wantRespVal interface{}
+	}{{
+		desc:       "get PORT Ethernet68 alias with namespace key",
+		pathTarget: "CONFIG_DB",
+		textPbPath: `
+			elem: <name: "PORT" key: <key: "namespace" value: "localhost" > >
+			elem: <name: "Ethernet68" >
+			elem: <name: "alias" >
+		`,
+		wantRetCode: codes.OK,
+		wantRespVal: "Ethernet68/1",
+	}, {
+		desc:       "get PORT Ethernet68 alias with origin",
+		pathTarget: "CONFIG_DB",
+		textPbPath: `
+			origin: "localhost"
+			elem: <name: "PORT" >
+			elem: <name: "Ethernet68" >
+			elem: <name: "alias" >
+		`,
+		wantRetCode: codes.OK,
+		wantRespVal: "Ethernet68/1",
+	}, {
+		desc:       "get PORT Ethernet68 alias with namespace in target",
+		pathTarget: "localhost/CONFIG_DB",
+		textPbPath: `
+			elem: <name: "PORT" >
+			elem: <name: "Ethernet68" >
+			elem: <name: "alias" >
+		`,
+		wantRetCode: codes.OK,
+		wantRespVal: "Ethernet68/1",
+	}, {
+		desc:       "get PORT Ethernet68 alias in all namespaces",
+		pathTarget: "CONFIG_DB",
+		textPbPath: `
+			elem: <name: "PORT" key: <key: "namespace" value: "*" > >
+			elem: <name: "Ethernet68" >
+			elem: <name: "alias" >
+		`,
+		wantRetCode: codes.OK,
+		wantRespVal: "Ethernet68/1",
+	}, {
+		desc:       "get PORT Ethernet68 alias with invalid namespace",
+		pathTarget: "CONFIG_DB",
+		textPbPath: `
+			elem: <name: "PORT" key: <key: "namespace" value: "asic9" > >
+			elem: <name: "Ethernet68" >
+			elem: <name: "alias" >
+		`,
+		wantRetCode: codes.NotFound,
+	}, {
+		desc:       "get PORT Ethernet68 alias with invalid namespace in target",
+		pathTarget: "asic9/CONFIG_DB",
+		textPbPath: `
+			elem: <name: "PORT" >
+			elem: <name: "Ethernet68" >
+			elem: <name: "alias" >
+		`,
+		wantRetCode: codes.NotFound,
+	}}
+
+	for _, td := range tds {
+		t.Run(td.desc, func(t *testing.T) {
+			runTestGet(t, ctx, gClient, td.pathTarget, td.textPbPath, td.wantRetCode, td.wantRespVal, true)
+		})
+	}
+}
+
+func TestGnmiGetMultiNamespace(t *testing.T) {
+	// database_global.json next to it adds namespace asic0
+	if errs := sdc.InitDbConfig("../testdata/database_config.json"); len(errs) != 0 {
+		t.Fatalf("Invalid database config: %v", errs)
+	}
+	defer sdc.InitDbConfig(sdcfg.SONIC_DB_CONFIG_FILE)
+
+	s := createServer(t)
+	go runServer(t, s)
+	defer s.s.Stop()
+
+	prepareDb(t)
+	asic0Db := func(dbName string) *redis.Client {
+		dbn, err := sdcfg.GetDbIdNs("asic0", dbName)
+		if err != nil {
+			t.Fatalf("failed to get redis config %v", err)
+		}
+		rclient := getRedisClientN(t, dbn)
+		rclient.FlushDB()
+		return rclient
+	}
+	asic0Counters := asic0Db("COUNTERS_DB")
+	defer asic0Counters.Close()
+	asic0Config := asic0Db("CONFIG_DB")
+	defer asic0Config.Close()
+	loadDB(t, asic0Counters, map[string]interface{}{
+		"COUNTERS_PORT_NAME_MAP":       map[string]interface{}{"Ethernet400": "oid:0x1000000000400"},
+		"COUNTERS:oid:0x1000000000400": map[string]interface{}{"SAI_PORT_STAT_PFC_7_RX_PKTS": "4"},
+	})
+	loadDB(t, asic0Config, map[string]interface{}{
+		"PORT|Ethernet400":          map[string]interface{}{"alias": "Ethernet400/1"},
+		"DEVICE_METADATA|localhost": map[string]interface{}{"hostname": "sonic-asic0"},
+	})
+	configDb := getRedisClientN(t, 4)
+	defer configDb.Close()
+	loadDB(t, configDb, map[string]interface{}{
+		"DEVICE_METADATA|localhost": map[string]interface{}{"hostname": "sonic-host"},
+	})
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
+	targetAddr := "127.0.0.1:8081"
+	conn, err := grpc.Dial(targetAddr, opts...)
+	if err != nil {
+		t.Fatalf("Dialing to %q failed: %v", targetAddr, err)
+	}
+	defer conn.Close()
+
+	gClient := pb.NewGNMIClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("get PORT Ethernet400 alias in asic0", func(t *testing.T) {
+		textPbPath := `
+			elem: <name: "PORT" key: <key: "namespace" value: "asic0" > >
+			elem: <name: "Ethernet400" >
+			elem: <name: "alias" >
+		`
+		runTestGet(t, ctx, gClient, "CONFIG_DB", textPbPath, codes.OK, "Ethernet400/1", true)
+		textPbPath = `
+			elem: <name: "PORT" >
+			elem: <name: "Ethernet400" >
+			elem: <name: "alias" >
+		`
+		runTestGet(t, ctx, gClient, "asic0/CONFIG_DB", textPbPath, codes.OK, "Ethernet400/1", true)
+		runTestGet(t, ctx, gClient, "CONFIG_DB", textPbPath, codes.NotFound, nil, false)
+	})
+
+	t.Run("get COUNTERS Ethernet400 in asic0", func(t *testing.T) {
+		textPbPath := `
+			origin: "asic0"
+			elem: <name: "COUNTERS" >
+			elem: <name: "Ethernet400/1" >
+			elem: <name: "SAI_PORT_STAT_PFC_7_RX_PKTS" >
+		`
+		runTestGet(t, ctx, gClient, "COUNTERS_DB", textPbPath, codes.OK, "4", true)
+	})
+
+	t.Run("get DEVICE_METADATA hostname in all namespaces", func(t *testing.T) {
+		var pbPath pb.Path
+		textPbPath := `
+			elem: <name: "DEVICE_METADATA" key: <key: "namespace" value: "*" > >
+			elem: <name: "localhost" >
+			elem: <name: "hostname" >
+		`
+		if err := proto.UnmarshalText(textPbPath, &pbPath); err != nil {
+			t.Fatalf("error in unmarshaling path: %v %v", textPbPath, err)
+		}
+		resp, err := gClient.Get(ctx, &pb.GetRequest{
+			Prefix:   &pb.Path{Target: "CONFIG_DB"},
+			Path:     []*pb.Path{&pbPath},
+			Encoding: pb.Encoding_JSON_IETF,
+		})
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		// Each namespace is in its own update, tagged with the namespace
+		got := make(map[string]string)
+		for _, notif := range resp.GetNotification() {
+			for _, update := range notif.GetUpdate() {
+				ns := update.GetPath().GetElem()[0].GetKey()["namespace"]
+				got[ns] = update.GetVal().GetStringVal()
+			}
+		}
+		want := map[string]string{"localhost": "sonic-host", "asic0": "sonic-asic0"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("get COUNTERS Ethernet* SAI_PORT_STAT_PFC_7_RX_PKTS in all namespaces", func(t *testing.T) {
+		// Virtual path gives the aggregate view of ports of all ASICs
+		textPbPath := `
+			elem: <name: "COUNTERS" key: <key: "namespace" value: "*" > >
+			elem: <name: "Ethernet*" >
+			elem: <name: "SAI_PORT_STAT_PFC_7_RX_PKTS" >
+		`
+		wantRespVal := []byte(`{
+			"Ethernet1/1": {"SAI_PORT_STAT_PFC_7_RX_PKTS": "1"},
+			"Ethernet68/1": {"SAI_PORT_STAT_PFC_7_RX_PKTS": "2"},
+			"Ethernet400/1": {"SAI_PORT_STAT_PFC_7_RX_PKTS": "4"}
+		}`)
+		runTestGet(t, ctx, gClient, "COUNTERS_DB", textPbPath, codes.OK, wantRespVal, true)
+	})
+}
+
+func TestVirtualPathAggregate(t *testing.T) {
+	counters := map[string]interface{}{
+		"COUNTERS_PORT_NAME_MAP": map[string]interface{}{
+			"Ethernet300": "oid:0x1000000000301",
+			"Ethernet304": "oid:0x1000000000302",
+		},
+		"COUNTERS:oid:0x1000000000301": map[string]interface{}{
+			"SAI_PORT_STAT_IF_IN_OCTETS":  "100",
+			"SAI_PORT_STAT_IF_OUT_OCTETS": "10",
+		},
+		"COUNTERS:oid:0x1000000000302": map[string]interface{}{
+			"SAI_PORT_STAT_IF_IN_OCTETS":  "200",
+			"SAI_PORT_STAT_IF_OUT_OCTETS": "20",
+		},
+	}
+	config := map[string]interface{}{
+		"PORTCHANNEL|PortChannel0001":                    map[string]interface{}{"admin_status": "up"},
+		"PORTCHANNEL_MEMBER|PortChannel0001|Ethernet300": map[string]interface{}{"NULL": "NULL"},
+		"PORTCHANNEL_MEMBER|PortChannel0001|Ethernet304": map[string]interface{}{"NULL": "NULL"},
+		"VLAN|Vlan1000":                    map[string]interface{}{"vlanid": "1000"},
+		"VLAN_MEMBER|Vlan1000|Ethernet300": map[string]interface{}{"tagging_mode": "untagged"},
+	}
+	gClient, stop := startVirtualPathServer(t, counters, config)
+	defer stop()
+
+	runVirtualPathGets(t, gClient, []virtualPathGetTest{{
+		desc: "get AGGREGATE PortChannel0001",
+		textPbPath: `
+			elem: <name: "AGGREGATE" >
+			elem: <name: "PortChannel0001" >
+		`,
+		wantRetCode: codes.OK,
+		wantRespVal: []byte(`{"SAI_PORT_STAT_IF_IN_OCTETS": "300", "SAI_PORT_STAT_IF_OUT_OCTETS": "30"}`),
+	}, {
+		desc: "get AGGREGATE PortChannel0001 SAI_PORT_STAT_IF_IN_OCTETS",
+		textPbPath: `
+			elem: <name: "AGGREGATE" >
+			elem: <name: "PortChannel0001" >
+			elem: <name: "SAI_PORT_STAT_IF_IN_OCTETS" >
+		`,
+		wantRetCode: codes.OK,
+		wantRespVal: "300",
+	}, {
+		desc: "get AGGREGATE Vlan* Members",
+		textPbPath: `
+			elem: <name: "AGGREGATE" >
+			elem: <name: "Vlan*" >
+			elem: <name: "Members" >
+		`,
+		wantRetCode: codes.OK,
+		wantRespVal: []byte(`{"Vlan1000": {"SAI_PORT_STAT_IF_IN_OCTETS": "100", "SAI_PORT_STAT_IF_OUT_OCTETS": "10",
+			"members": {"Ethernet300": {"SAI_PORT_STAT_IF_IN_OCTETS": "100", "SAI_PORT_STAT_IF_OUT_OCTETS": "10"}}}}`),
+	}, {
+		desc: "get AGGREGATE PortChannel0002",
+		textPbPath: `
+			elem: <name: "AGGREGATE" >
+			elem: <name: "PortChannel0002" >
+		`,
+		wantRetCode: codes.NotFound,
+	}})
+}
+
+func TestVirtualPathRates(t *testing.T) {
+	gClient, stop := startVirtualPathServer(t, map[string]interface{}{
+		"COUNTERS_PORT_NAME_MAP": map[string]interface{}{
+			"Ethernet308": "oid:0x1000000000303",
+		},
+		"COUNTERS:oid:0x1000000000303": map[string]interface{}{
+			"SAI_PORT_STAT_IF_IN_OCTETS":     "1000",
+			"SAI_PORT_STAT_IF_IN_UCAST_PKTS": "10",
+		},
+	}, map[string]interface{}{
+		"PORT|Ethernet308": map[string]interface{}{"alias": "Ethernet308/1", "speed": "100000"},
+	})
+	defer stop()
+	rclient := getRedisClient(t)
+	defer rclient.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The first read starts sampling, rates are ready after a sample interval
+	runTestGet(t, ctx, gClient, "COUNTERS_DB", `elem: <name: "RATES" > elem: <name: "Ethernet308" >`, codes.NotFound, nil, false)
+
+	// Stream subscription waits for the rates instead of failing
+	var ratePath pb.Path
+	proto.UnmarshalText(`elem: <name: "RATES" > elem: <name: "Ethernet308" > elem: <name: "RX_BPS" >`, &ratePath)
+	stream, err := gClient.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	err = stream.Send(&pb.SubscribeRequest{
+		Request: &pb.SubscribeRequest_Subscribe{
+			Subscribe: &pb.SubscriptionList{
+				Prefix:       &pb.Path{Target: "COUNTERS_DB"},
+				Mode:         pb.SubscriptionList_STREAM,
+				Subscription: []*pb.Subscription{{Path: &ratePath}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Send SubscribeRequest failed: %v", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil || len(resp.GetUpdate().GetUpdate()) != 1 || resp.GetUpdate().GetUpdate()[0].GetVal().GetStringVal() != "0.00" {
+		t.Fatalf("got %v, %v, want RX_BPS update of 0.00", resp, err)
+	}
+	stream.CloseSend()
+
+	runVirtualPathGets(t, gClient, []virtualPathGetTest{{
+		desc: "get RATES Ethernet308 RX_BPS of static counters",
+		textPbPath: `
+			elem: <name: "RATES" >
+			elem: <name: "Ethernet308" >
+			elem: <name: "RX_BPS" >
+		`,
+		wantRetCode: codes.OK,
+		wantRespVal: "0.00",
+	}, {
+		desc: "get RATES Ethernet308 RX_UTIL of static counters",
+		textPbPath: `
+			elem: <name: "RATES" >
+			elem: <name: "Ethernet308" >
+			elem: <name: "RX_UTIL" >
+		`,
+		wantRetCode: codes.OK,
+		wantRespVal: "0.00",
+	}, {
+		desc: "get RATES of non-existing Ethernet400",
+		textPbPath: `
+			elem: <name: "RATES" >
+			elem: <name: "Ethernet400" >
+		`,
+		wantRetCode: codes.NotFound,
+	}})
+
+	// Counters increase, then reset which should not give negative rate
+	for _, octets := range []string{"1251000", "500"} {
+		rclient.HSet("COUNTERS:oid:0x1000000000303", "SAI_PORT_STAT_IF_IN_OCTETS", octets)
+		// wait for counters to be sampled
+		time.Sleep(2 * time.Second)
+
+		var pbPath pb.Path
+		proto.UnmarshalText(`elem: <name: "RATES" > elem: <name: "Ethernet308" > elem: <name: "RX_BPS" >`, &pbPath)
+		resp, err := gClient.Get(ctx, &pb.GetRequest{
+			Prefix:   &pb.Path{Target: "COUNTERS_DB"},
+			Path:     []*pb.Path{&pbPath},
+			Encoding: pb.Encoding_JSON_IETF,
+		})
+		if err != nil {
+			t.Fatalf("Get RX_BPS failed: %v", err)
+		}
+		bps, err := strconv.ParseFloat(resp.GetNotification()[0].GetUpdate()[0].GetVal().GetStringVal(), 64)
+		if err != nil || bps <= 0 {
+			t.Errorf("got RX_BPS %v %v after counters at %v, want positive rate", bps, err, octets)
+		}
+	}
+}
+
+// TestCountersCollector collects COUNTERS_DB counters of the fixture data
+// and checks metric names, types, labels and values.
+func TestCountersCollector(t *testing.T) {
+	prepareDb(t)
+	rclient := getRedisClient(t)
+	defer rclient.Close()
+	loadDB(t, rclient, map[string]interface{}{
+		// Ethernet68
+		"COUNTERS:oid:0x1000000000039": map[string]interface{}{
+			"SAI_PORT_STAT_IF_IN_OCTETS": "12345678901",
+		},
+		// Ethernet68:1
+		"COUNTERS:oid:0x1500000000091c": map[string]interface{}{
+			"SAI_QUEUE_STAT_PACKETS": "42",
+		},
+		// Ethernet68:3
+		"COUNTERS:oid:0x1500000000091e": map[string]interface{}{
+			"PFC_WD_QUEUE_STATS_DEADLOCK_DETECTED": "7",
+		},
+	})
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(sdc.NewCountersCollector(sdc.NewRedisConnManager(sdc.RedisConfig{})))
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	families := make(map[string]*dto.MetricFamily)
+	for _, mf := range mfs {
+		families[mf.GetName()] = mf
+	}
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   float64
+	}{{
+		name:   "sonic_port_if_in_octets_total",
+		labels: map[string]string{"interface": "Ethernet68", "alias": "Ethernet68/1"},
+		want:   12345678901,
+	}, {
+		name:   "sonic_queue_packets_total",
+		labels: map[string]string{"interface": "Ethernet68", "alias": "Ethernet68/1", "queue": "1"},
+		want:   42,
+	}, {
+		name:   "sonic_pfcwd_deadlock_detected_total",
+		labels: map[string]string{"interface": "Ethernet68", "alias": "Ethernet68/1", "queue": "3"},
+		want:   7,
+	}}
+	for _, tt := range tests {
+		mf, ok := families[tt.name]
+		if !ok {
+			t.Errorf("metric %v not collected", tt.name)
+			continue
+		}
+		if mf.GetType() != dto.MetricType_COUNTER {
+			t.Errorf("metric %v got type %v, want COUNTER", tt.name, mf.GetType())
+		}
+		found := false
+		for _, m := range mf.GetMetric() {
+			labels := make(map[string]string)
+			for _, lp := range m.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			if !reflect.DeepEqual(labels, tt.labels) {
+				continue
+			}
+			found = true
+			if got := m.GetCounter().GetValue(); got != tt.want {
+				t.Errorf("metric %v%v got %v, want %v", tt.name, tt.labels, got, tt.want)
+			}
+		}
+		if !found {
+			t.Errorf("metric %v%v not collected", tt.name, tt.labels)
+		}
+	}
+
+	// Configuration, status and gauge fields are not counters
+	for _, name := range []string{"sonic_pfcwd_detection_time_total", "sonic_pfcwd_status_total",
+		"sonic_queue_curr_occupancy_bytes_total", "sonic_queue_packets_last_total"} {
+		if _, ok := families[name]; ok {
+			t.Errorf("metric %v collected, want not", name)
+		}
+	}
+}
+
+// TestVirtualDbConcurrentClients runs Get and stream Subscribe clients on
+// virtual paths concurrently while the port name map changes.
+// Run it with -race to detect unsynchronized access to the name maps.
+func TestVirtualDbConcurrentClients(t *testing.T) {
+	s := createServer(t)
+	go runServer(t, s)
+	defer s.s.Stop()
+
+	prepareDb(t)
+	rclient := getRedisClient(t)
+	defer rclient.Close()
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
+	targetAddr := "127.0.0.1:8081"
+	conn, err := grpc.Dial(targetAddr, opts...)
+	if err != nil {
+		t.Fatalf("Dialing to %q failed: %v", targetAddr, err)
+	}
+	defer conn.Close()
+
+	gClient := pb.NewGNMIClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	prefix := &pb.Path{Target: "COUNTERS_DB"}
+	portsPath := &pb.Path{Elem: []*pb.PathElem{{Name: "COUNTERS"}, {Name: "Ethernet*"}}}
+	queuesPath := &pb.Path{Elem: []*pb.PathElem{{Name: "COUNTERS"}, {Name: "Ethernet68"}, {Name: "Queues"}}}
+
+	const clients = 4
+	var wg sync.WaitGroup
+	errc := make(chan error, 4*clients)
+	done := make(chan struct{})
+
+	// Concurrent Get clients
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				req := &pb.GetRequest{
+					Prefix:   prefix,
+					Path:     []*pb.Path{portsPath, queuesPath},
+					Encoding: pb.Encoding_JSON_IETF,
+				}
+				if _, err := gClient.Get(ctx, req); err != nil {
+					errc <- fmt.Errorf("Get failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+
+	// Concurrent stream clients, each waits for the added port to show up
+	// and then to be deleted. Each has its own connection, as the server
+	// replaces the client of same peer address.
+	newPort := "Ethernet200"
+	synced := make(chan struct{}, clients)
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := grpc.Dial(targetAddr, opts...)
+			if err != nil {
+				errc <- fmt.Errorf("Dialing to %q failed: %v", targetAddr, err)
+				return
+			}
+			defer conn.Close()
+			stream, err := pb.NewGNMIClient(conn).Subscribe(ctx)
+			if err != nil {
+				errc <- fmt.Errorf("Subscribe failed: %v", err)
+				return
+			}
+			req := &pb.SubscribeRequest{
+				Request: &pb.SubscribeRequest_Subscribe{
+					Subscribe: &pb.SubscriptionList{
+						Prefix:       prefix,
+						Mode:         pb.SubscriptionList_STREAM,
+						Subscription: []*pb.Subscription{{Path: portsPath}},
+					},
+				},
+			}
+			if err = stream.Send(req); err != nil {
+				errc <- fmt.Errorf("Send SubscribeRequest failed: %v", err)
+				return
+			}
+			added := false
+			for {
+				resp, err := stream.Recv()
+				if err != nil {
+					errc <- fmt.Errorf("Recv failed: %v", err)
+					return
+				}
+				if resp.GetSyncResponse() {
+					synced <- struct{}{}
+					continue
+				}
+				notif := resp.GetUpdate()
+				for _, u := range notif.GetUpdate() {
+					var v map[string]interface{}
+					json.Unmarshal(u.GetVal().GetJsonIetfVal(), &v)
+					if _, ok := v[newPort]; ok {
+						added = true
+					}
+				}
+				for _, d := range notif.GetDelete() {
+					elems := d.GetElem()
+					if added && len(elems) > 0 && elems[len(elems)-1].GetName() == newPort {
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < clients; i++ {
+		select {
+		case <-synced:
+		case err := <-errc:
+			t.Fatal(err)
+		case <-ctx.Done():
+			t.Fatal("Timeout waiting for sync_response")
+		}
+	}
+	// Port added to and then removed from the name map
+	if err = rclient.HSet("COUNTERS_PORT_NAME_MAP", newPort, "oid:0x1000000000003").Err(); err != nil {
+		t.Fatalf("HSet failed: %v", err)
+	}
+	time.Sleep(2 * time.Second)
+	if err = rclient.HDel("COUNTERS_PORT_NAME_MAP", newPort).Err(); err != nil {
+		t.Fatalf("HDel failed: %v", err)
+	}
+	time.Sleep(2 * time.Second)
+	close(done)
+	wg.Wait()
+	close(errc)
+	for err := range errc {
+		t.Error(err)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	//t.Log("Start server")
+	s := createServer(t)
+	go runServer(t, s)
+
+	// prepareDb(t)
+
+	//t.Log("Start gNMI client")
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
+
+	//targetAddr := "30.57.185.38:8080"
+	targetAddr := "127.0.0.1:8081"
+	conn, err := grpc.Dial(targetAddr, opts...)
+	if err != nil {
+		t.Fatalf("Dialing to %q failed: %v", targetAddr, err)
+	}
+	defer conn.Close()
+
+	gClient := pb.NewGNMIClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var req pb.CapabilityRequest
+	resp, err := gClient.Capabilities(ctx, &req)
+	if err != nil {
+		t.Fatalf("Failed to get Capabilities")
+	}
+	if len(resp.SupportedModels) == 0 {
+		t.Fatalf("No Supported Models found!")
+	}
+
+}
+
+// Redis connections of the tests, via tcp localhost
+var testRedisConn = sdc.NewRedisConnManager(sdc.RedisConfig{UseTcp: true})
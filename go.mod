@@ -3,6 +3,7 @@ module github.com/Azure/sonic-telemetry
 go 1.12
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/Workiva/go-datastructures v1.0.50
 	github.com/antchfx/jsonquery v1.1.0
 	github.com/antchfx/xmlquery v1.2.1
@@ -22,6 +23,8 @@ require (
 	github.com/openconfig/goyang v0.0.0-20190924211109-064f9690516f
 	github.com/openconfig/ygot v0.6.1-0.20190723223108-724a6b18a922
 	github.com/pborman/getopt v0.0.0-20190409184431-ee0cd42419d3 // indirect
+	github.com/prometheus/client_golang v1.3.0
+	github.com/prometheus/client_model v0.1.0
 	github.com/stretchr/testify v1.4.0 // indirect
 	golang.org/x/crypto v0.0.0-20191206172530-e9b2fee46413 // indirect
 	golang.org/x/lint v0.0.0-20190313153728-d0100b6bd8b3 // indirect
@@ -0,0 +1,167 @@
+// Package metrics provides Prometheus metrics of the telemetry services
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-redis/redis"
+	log "github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "telemetry"
+)
+
+var (
+	// RpcRequests counts gNMI RPCs by method and returned status code
+	RpcRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "gnmi",
+			Name:      "rpc_requests_total",
+			Help:      "Number of gNMI RPCs handled, by method and status code.",
+		},
+		[]string{"method", "code"},
+	)
+
+	// RpcDuration observes gNMI RPC latency. For Subscribe it is the
+	// lifetime of the stream.
+	RpcDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "gnmi",
+			Name:      "rpc_duration_seconds",
+			Help:      "Latency of gNMI RPCs, by method.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+		},
+		[]string{"method"},
+	)
+
+	// ActiveSubscriptions is the number of running Subscribe clients
+	ActiveSubscriptions = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "gnmi",
+			Name:      "subscriptions_active",
+			Help:      "Number of active Subscribe clients, by target and mode.",
+		},
+		[]string{"target", "mode"},
+	)
+
+	// RedisOpDuration observes latency of redis commands issued by data clients
+	RedisOpDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "redis",
+			Name:      "op_duration_seconds",
+			Help:      "Latency of redis operations, by db and command.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 8),
+		},
+		[]string{"db", "op"},
+	)
+
+	// DialoutConnected is 1 if the dialout subscription is connected to the destination
+	DialoutConnected = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "dialout",
+			Name:      "connected",
+			Help:      "Whether the dialout subscription is connected to the destination.",
+		},
+		[]string{"subscription", "destination"},
+	)
+
+	// DialoutConnectAttempts counts connection attempts of a dialout subscription
+	DialoutConnectAttempts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "dialout",
+			Name:      "connect_attempts_total",
+			Help:      "Number of connection attempts, by dialout subscription.",
+		},
+		[]string{"subscription"},
+	)
+
+	// DialoutAcks counts PublishResponse acknowledgements received by a dialout subscription
+	DialoutAcks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "dialout",
+			Name:      "acks_total",
+			Help:      "Number of PublishResponse acknowledgements received, by dialout subscription.",
+		},
+		[]string{"subscription"},
+	)
+
+	// DialoutUnacked is the number of notifications kept for resend in reliable mode
+	DialoutUnacked = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "dialout",
+			Name:      "unacked_notifications",
+			Help:      "Number of unacknowledged notifications kept for resend, by dialout subscription.",
+		},
+		[]string{"subscription"},
+	)
+
+	// DialoutResendDropped counts unacknowledged notifications dropped from full resend buffer
+	DialoutResendDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "dialout",
+			Name:      "resend_dropped_total",
+			Help:      "Number of unacknowledged notifications dropped from full resend buffer, by dialout subscription.",
+		},
+		[]string{"subscription"},
+	)
+)
+
+// InstrumentRedis records latency of every command run by the redis client
+// under the given db name.
+func InstrumentRedis(dbName string, redisDb *redis.Client) {
+	redisDb.WrapProcess(func(old func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			start := time.Now()
+			err := old(cmd)
+			RedisOpDuration.WithLabelValues(dbName, cmd.Name()).Observe(time.Since(start).Seconds())
+			return err
+		}
+	})
+}
+
+// Serve starts the HTTP listener for Prometheus scraping on the port,
+// it blocks until the listener fails. Telemetry service metrics are served
+// at /metrics. If counters collector is provided, the device counters are
+// served at /counters, which may be scraped at a different interval.
+func Serve(port int, counters prometheus.Collector) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	if counters != nil {
+		reg := prometheus.NewRegistry()
+		if err := reg.Register(counters); err != nil {
+			return fmt.Errorf("failed to register counters collector: %v", err)
+		}
+		mux.Handle("/counters", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	}
+	addr := fmt.Sprintf(":%d", port)
+	log.V(1).Infof("Serving metrics on %s/metrics", addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+func init() {
+	prometheus.MustRegister(
+		RpcRequests,
+		RpcDuration,
+		ActiveSubscriptions,
+		RedisOpDuration,
+		DialoutConnected,
+		DialoutConnectAttempts,
+		DialoutAcks,
+		DialoutUnacked,
+		DialoutResendDropped,
+	)
+}
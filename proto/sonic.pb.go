@@ -29,17 +29,33 @@ func (x Target) String() string {
 }
 func (Target) EnumDescriptor() ([]byte, []int) { return fileDescriptor1, []int{0} }
 
-func setTarget() {
-    db_list := sdcfg.GetDbList()
-    for dbname, v := range db_list {
-        id := v.(map[string]interface{})["id"].(float64)
+// SetTarget sets the targets to DBs in database config. DBs with invalid
+// config are skipped.
+func SetTarget() error {
+    db_list, err := sdcfg.GetDbList()
+    if err != nil {
+        return err
+    }
+    for dbname, id := range Target_value {
+        if dbname != "OTHERS" {
+            delete(Target_value, dbname)
+            delete(Target_name, id)
+        }
+    }
+    for dbname := range db_list {
+        id, err := sdcfg.GetDbId(dbname)
+        if err != nil {
+            continue
+        }
         Target_value[dbname] = int32(id)
         Target_name[int32(id)] = dbname
     }
+    return nil
 }
 
 func init() {
-	setTarget()
+	// Problems of database config are reported when the server starts
+	SetTarget()
 	proto.RegisterEnum("gnmi.sonic.Target", Target_name, Target_value)
 }
 
@@ -127,14 +127,22 @@ func runTestGet(ctx context.Context, gClient pb.GNMIClient, pathTarget string,
 }
 
 func getRedisClient() *redis.Client {
+	addr, err := sdcfg.GetDbTcpAddr("COUNTERS_DB")
+	if err != nil {
+		log.Fatalf("failed to get redis config %v", err)
+	}
+	dbn, err := sdcfg.GetDbId("COUNTERS_DB")
+	if err != nil {
+		log.Fatalf("failed to get redis config %v", err)
+	}
 	rclient := redis.NewClient(&redis.Options{
 		Network:     "tcp",
-		Addr:        sdcfg.GetDbTcpAddr("COUNTERS_DB"),
+		Addr:        addr,
 		Password:    "", // no password set
-		DB:          sdcfg.GetDbId("COUNTERS_DB"),
+		DB:          dbn,
 		DialTimeout: 0,
 	})
-	_, err := rclient.Ping().Result()
+	_, err = rclient.Ping().Result()
 	if err != nil {
 		log.Fatalf("failed to connect to redis server %v", err)
 	}
@@ -142,14 +150,22 @@ func getRedisClient() *redis.Client {
 }
 
 func getConfigDbClient() *redis.Client {
+	addr, err := sdcfg.GetDbTcpAddr("CONFIG_DB")
+	if err != nil {
+		log.Fatalf("failed to get redis config %v", err)
+	}
+	dbn, err := sdcfg.GetDbId("CONFIG_DB")
+	if err != nil {
+		log.Fatalf("failed to get redis config %v", err)
+	}
 	rclient := redis.NewClient(&redis.Options{
 		Network:     "tcp",
-		Addr:        sdcfg.GetDbTcpAddr("CONFIG_DB"),
+		Addr:        addr,
 		Password:    "", // no password set
-		DB:          sdcfg.GetDbId("CONFIG_DB"),
+		DB:          dbn,
 		DialTimeout: 0,
 	})
-	_, err := rclient.Ping().Result()
+	_, err = rclient.Ping().Result()
 	if err != nil {
 		log.Fatalf("failed to connect to redis server %v", err)
 	}
@@ -0,0 +1,151 @@
+package client
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	log "github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+
+	sdcfg "github.com/Azure/sonic-telemetry/sonic_db_config"
+)
+
+// Counters collector is to export port, queue and PFC watchdog counters in
+// COUNTERS_DB as Prometheus counters. The real data paths are resolved with
+// the same virtual path translation as gNMI requests.
+
+type countersGroup struct {
+	// virtual path to be translated, ex. [COUNTERS_DB COUNTERS Ethernet* Queues]
+	path []string
+	// metric name prefix
+	subsystem string
+	// prefix of the counter names to be stripped in metric name, fields
+	// without it are not exported
+	fieldPrefix string
+	// whether jsonTableKey has queue index, ex. "Ethernet68/1:3"
+	hasQueue bool
+}
+
+var (
+	countersGroups = []countersGroup{
+		{
+			path:        []string{"COUNTERS_DB", "COUNTERS", "Ethernet*"},
+			subsystem:   "port",
+			fieldPrefix: "SAI_PORT_STAT_",
+		}, {
+			path:        []string{"COUNTERS_DB", "COUNTERS", "Ethernet*", "Queues"},
+			subsystem:   "queue",
+			fieldPrefix: "SAI_QUEUE_STAT_",
+			hasQueue:    true,
+		}, {
+			path:        []string{"COUNTERS_DB", "COUNTERS", "Ethernet*", "Pfcwd"},
+			subsystem:   "pfcwd",
+			fieldPrefix: "PFC_WD_QUEUE_STATS_",
+			hasQueue:    true,
+		},
+	}
+
+	// Gauges and last polled values are not exported as counters
+	nonCounterFields = regexp.MustCompile("CURR_OCCUPANCY|WATERMARK|_last$")
+
+	invalidMetricChars = regexp.MustCompile("[^a-zA-Z0-9_]")
+)
+
+// CountersCollector implements prometheus.Collector for COUNTERS_DB data.
+// Counter values are read from redis at scrape time. Metrics of a group have
+// the same label set, interface and alias, plus queue for queue and pfcwd.
+type CountersCollector struct {
+	conn *RedisConnManager
+}
+
+// NewCountersCollector returns a collector exporting COUNTERS_DB counters,
+// read with the connection manager.
+func NewCountersCollector(conn *RedisConnManager) *CountersCollector {
+	return &CountersCollector{conn: conn}
+}
+
+// Describe implements prometheus.Collector. Counter names are only known
+// after reading the DB, so nothing is sent and the collector is unchecked by
+// the registry. Label sets are fixed per metric name, so the metrics are
+// still consistent across scrapes.
+func (cc *CountersCollector) Describe(ch chan<- *prometheus.Desc) {
+}
+
+// Collect implements prometheus.Collector
+func (cc *CountersCollector) Collect(ch chan<- prometheus.Metric) {
+	v := cc.conn.virtualDb(sdcfg.SONIC_DEFAULT_NAMESPACE)
+	if err := v.init(); err != nil {
+		log.V(1).Infof("Failed to init COUNTERS_DB maps: %v", err)
+		return
+	}
+	separator, _ := GetTableKeySeparator("COUNTERS_DB")
+	maps := v.loadMaps()
+
+	for _, grp := range countersGroups {
+		tblPaths, err := lookupV2R(maps, grp.path)
+		if err != nil {
+			log.V(2).Infof("v2r translation failed for %v: %v", grp.path, err)
+			continue
+		}
+
+		for _, tblPath := range tblPaths {
+			msi := make(map[string]interface{})
+			if err := tableData2Msi(cc.conn, &tblPath, false, nil, &msi); err != nil {
+				log.V(2).Infof("Failed to read %v: %v", tblPath, err)
+				continue
+			}
+			fv, ok := msi[tblPath.jsonTableKey].(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			alias := tblPath.jsonTableKey
+			var queue string
+			if grp.hasQueue {
+				idx := strings.LastIndex(alias, separator)
+				if idx < 0 {
+					continue
+				}
+				alias, queue = alias[:idx], alias[idx+len(separator):]
+			}
+			name := alias
+			if val, ok := maps.alias2nameMap[alias]; ok {
+				name = val
+			}
+			labels := []string{"interface", "alias"}
+			values := []string{name, alias}
+			if grp.hasQueue {
+				labels = append(labels, "queue")
+				values = append(values, queue)
+			}
+
+			for field, v := range fv {
+				if !strings.HasPrefix(field, grp.fieldPrefix) || nonCounterFields.MatchString(field) {
+					continue
+				}
+				s, _ := v.(string)
+				fval, err := strconv.ParseFloat(s, 64)
+				if err != nil {
+					continue
+				}
+				desc := prometheus.NewDesc(countersMetricName(grp, field),
+					field+" in COUNTERS_DB.", labels, nil)
+				m, err := prometheus.NewConstMetric(desc, prometheus.CounterValue, fval, values...)
+				if err != nil {
+					log.V(2).Infof("Invalid metric for %v %v: %v", tblPath, field, err)
+					continue
+				}
+				ch <- m
+			}
+		}
+	}
+}
+
+// countersMetricName converts counter name to metric name,
+// ex. SAI_PORT_STAT_IF_IN_OCTETS to sonic_port_if_in_octets_total
+func countersMetricName(grp countersGroup, field string) string {
+	name := strings.TrimPrefix(field, grp.fieldPrefix)
+	name = invalidMetricChars.ReplaceAllString(strings.ToLower(name), "_") + "_total"
+	return prometheus.BuildFQName("sonic", grp.subsystem, name)
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,20 +17,32 @@ import (
 
 	spb "github.com/Azure/sonic-telemetry/proto"
 	sdcfg "github.com/Azure/sonic-telemetry/sonic_db_config"
+	"github.com/Workiva/go-datastructures/queue"
 	"github.com/go-redis/redis"
+	"github.com/golang/protobuf/proto"
 	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
-	"github.com/Workiva/go-datastructures/queue"
 )
 
 const (
 	// indentString represents the default indentation string used for
 	// JSON. Two spaces are used here.
-	indentString                 string = "  "
+	indentString string = "  "
+
+	// Backoff of redis reconnection after connection lost
+	redisRetryMin = 100 * time.Millisecond
+	redisRetryMax = 5 * time.Second
+
+	// Interval of computing virtual table data for stream subscription
+	virtualTablePollInterval = time.Second
 )
 
+// WildcardLimit is the maximum number of redis keys scanned and concrete
+// paths expanded for one wildcard path, a wider path fails instead of
+// loading the whole DB
+var WildcardLimit = 10000
+
 // Client defines a set of methods which every client must implement.
 // This package provides one implmentation for now: the DbClient
-//
 type Client interface {
 	// StreamRun will start watching service on data source
 	// and enqueue data change to the priority queue.
@@ -45,9 +58,9 @@ type Client interface {
 	// Get return data from the data source in format of *spb.Value
 	Get(w *sync.WaitGroup) ([]*spb.Value, error)
 	// Set data based on path and value
-	Set(path *gnmipb.Path,  t *gnmipb.TypedValue, op int) error
+	Set(path *gnmipb.Path, t *gnmipb.TypedValue, op int) error
 	// Capabilities of the switch
-	Capabilities() ([]gnmipb.ModelData)
+	Capabilities() []gnmipb.ModelData
 
 	// Close provides implemenation for explicit cleanup of Client
 	Close() error
@@ -57,14 +70,19 @@ type Stream interface {
 	Send(m *gnmipb.SubscribeResponse) error
 }
 
-// Let it be variable visible to other packages for now.
-// May add an interface function for it.
-var UseRedisLocalTcpPort bool = false
+// When redis connection is lost, stream subscriptions resend full data and
+// a fresh sync_response after redis recovered. If NotifyRedisGap is set, an
+// update of RedisGapPath is also sent at the beginning of the gap, with the
+// affected subscribed path as its string value.
+var NotifyRedisGap bool = false
 
-// redis client connected to each DB
-var Target2RedisDb = make(map[string]*redis.Client)
+// RedisGapPath is the path of redis gap notice, /telemetry/redis_gap. No DB
+// table is named in lower case, so it can't be mistaken for data.
+var RedisGapPath = &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "telemetry"}, {Name: "redis_gap"}}}
 
 type tablePath struct {
+	// namespace of multi-ASIC platform, empty for the default one
+	namespace string
 	dbName    string
 	tableName string
 	tableKey  string
@@ -78,6 +96,8 @@ type tablePath struct {
 	jsonTableKey  string
 	jsonDelimitor string
 	jsonField     string
+	// expanded from wildcard path with the keys in redis at the time
+	expanded bool
 }
 
 type Value struct {
@@ -97,6 +117,7 @@ func (val Value) Compare(other queue.Item) int {
 
 type DbClient struct {
 	prefix  *gnmipb.Path
+	paths   []*gnmipb.Path
 	pathG2S map[*gnmipb.Path][]tablePath
 	q       *queue.PriorityQueue
 	channel chan struct{}
@@ -108,47 +129,52 @@ type DbClient struct {
 	sendMsg int64
 	recvMsg int64
 	errors  int64
+	// Number of subscription routines waiting to resync after redis recovery
+	resyncing int
+
+	// prefix with DB name as target, namespace in target taken as origin
+	dbPrefix *gnmipb.Path
+	// Connections to redis
+	conn *RedisConnManager
+	// Whether some path is expanded from wildcards
+	expanded bool
 }
 
-func NewDbClient(paths []*gnmipb.Path, prefix *gnmipb.Path, redis_socket bool) (Client, error) {
+// NewDbClient returns DB client of the paths, which connects to redis with
+// the connection manager, or the default one via unix socket if it is nil.
+func NewDbClient(paths []*gnmipb.Path, prefix *gnmipb.Path, conn *RedisConnManager) (Client, error) {
 	var client DbClient
 	var err error
 
-	// Testing program may ask to use redis local tcp connection
-	UseRedisLocalTcpPort = redis_socket
-	log.V(1).Infof("Creating a new DB client. It's value is %d", UseRedisLocalTcpPort)
-	if UseRedisLocalTcpPort {
-		useRedisTcpClient()
-	        log.V(1).Infof("Creating TCP client to redis")
+	if conn == nil {
+		conn = defaultRedisConn
 	}
-	if prefix.GetTarget() == "COUNTERS_DB" {
-		err = initCountersPortNameMap()
-		if err != nil {
-			return nil, err
-		}
-		err = initCountersQueueNameMap()
-		if err != nil {
-			return nil, err
-		}
-		err = initAliasMap()
-		if err != nil {
-			return nil, err
-		}
-		err = initCountersPfcwdNameMap()
-		if err != nil {
-			return nil, err
+	log.V(1).Infof("Creating a new DB client, redis config %+v", conn.Config())
+	client.conn = conn
+	// Target may be of a namespace, ex. asic0/COUNTERS_DB
+	client.dbPrefix = prefix
+	if namespace, dbName := SplitDbTarget(prefix.GetTarget()); namespace != "" {
+		client.dbPrefix = &gnmipb.Path{
+			Origin:  namespace,
+			Target:  dbName,
+			Elem:    prefix.GetElem(),
+			Element: prefix.GetElement(),
 		}
 	}
-
 	client.prefix = prefix
+	client.paths = paths
 	client.pathG2S = make(map[*gnmipb.Path][]tablePath)
-	err = populateAllDbtablePath(prefix, paths, &client.pathG2S)
+	err = populateAllDbtablePath(conn, client.dbPrefix, paths, &client.pathG2S)
 
 	if err != nil {
 		return nil, err
-	} else {
-		return &client, nil
 	}
+	for _, tblPaths := range client.pathG2S {
+		if len(tblPaths) > 0 && tblPaths[0].expanded {
+			client.expanded = true
+		}
+	}
+	return &client, nil
 }
 
 // String returns the target the client is querying.
@@ -164,8 +190,20 @@ func (c *DbClient) StreamRun(q *queue.PriorityQueue, stop chan struct{}, w *sync
 	c.q = q
 	c.channel = stop
 
+	// Keys created or deleted later are not seen by the expanded paths
+	if c.expanded {
+		enqueFatalMsg(c, "Wildcard path is not supported in STREAM mode, use POLL or ONCE")
+		return
+	}
+
 	for gnmiPath, tblPaths := range c.pathG2S {
-		if tblPaths[0].field != "" {
+		if len(tblPaths) > 0 && isVirtualTable(tblPaths[0].tableName) {
+			c.w.Add(1)
+			c.synced.Add(1)
+			go dbVirtualTableSubscribe(gnmiPath, c)
+			continue
+		}
+		if len(tblPaths) > 0 && tblPaths[0].field != "" {
 			c.w.Add(1)
 			c.synced.Add(1)
 			if len(tblPaths) > 1 {
@@ -216,8 +254,17 @@ func (c *DbClient) PollRun(q *queue.PriorityQueue, poll chan struct{}, w *sync.W
 			return
 		}
 		t1 := time.Now()
-		for gnmiPath, tblPaths := range c.pathG2S {
-			val, err := tableData2TypedValue(tblPaths, nil)
+		pathG2S := c.pathG2S
+		if c.expanded {
+			// Wildcard paths are expanded again with current keys on each poll
+			pathG2S = make(map[*gnmipb.Path][]tablePath)
+			if err := populateAllDbtablePath(c.conn, c.dbPrefix, c.paths, &pathG2S); err != nil {
+				enqueFatalMsg(c, err.Error())
+				return
+			}
+		}
+		for gnmiPath, tblPaths := range pathG2S {
+			val, err := tableData2TypedValue(c.conn, tblPaths, nil)
 			if err != nil {
 				return
 			}
@@ -253,7 +300,7 @@ func (c *DbClient) Get(w *sync.WaitGroup) ([]*spb.Value, error) {
 	var values []*spb.Value
 	ts := time.Now()
 	for gnmiPath, tblPaths := range c.pathG2S {
-		val, err := tableData2TypedValue(tblPaths, nil)
+		val, err := tableData2TypedValue(c.conn, tblPaths, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -291,6 +338,19 @@ func ValToResp(val Value) (*gnmipb.SubscribeResponse, error) {
 			return nil, fmt.Errorf("%s", fatal)
 		}
 
+		// Value without data is the deletion of the path
+		if val.GetVal() == nil {
+			return &gnmipb.SubscribeResponse{
+				Response: &gnmipb.SubscribeResponse_Update{
+					Update: &gnmipb.Notification{
+						Timestamp: val.GetTimestamp(),
+						Prefix:    val.GetPrefix(),
+						Delete:    []*gnmipb.Path{val.GetPath()},
+					},
+				},
+			}, nil
+		}
+
 		return &gnmipb.SubscribeResponse{
 			Response: &gnmipb.SubscribeResponse_Update{
 				Update: &gnmipb.Notification{
@@ -315,47 +375,46 @@ func GetTableKeySeparator(target string) (string, error) {
 		return "", fmt.Errorf("%v not a valid path target", target)
 	}
 
-	var separator string = sdcfg.GetDbSeparator(target)
-	return separator, nil
+	return sdcfg.GetDbSeparator(target)
 }
 
-// For testing only
-func useRedisTcpClient() {
-	for dbName, dbn := range spb.Target_value {
-		if dbName != "OTHERS" {
-			// DB connector for direct redis operation
-			var redisDb *redis.Client
-			if UseRedisLocalTcpPort {
-				redisDb = redis.NewClient(&redis.Options{
-					Network:     "tcp",
-					Addr:        sdcfg.GetDbTcpAddr(dbName),
-					Password:    "", // no password set
-					DB:          int(dbn),
-					DialTimeout: 0,
-				})
-			}
-			Target2RedisDb[dbName] = redisDb
-		}
+// IsDbTarget tells whether the target is a DB in database config, which may
+// be of a namespace on multi-ASIC platforms, ex. asic0/CONFIG_DB. The targets
+// are not limited to those known by the Target enum.
+func IsDbTarget(target string) bool {
+	namespace, dbName := SplitDbTarget(target)
+	if namespace == defaultNamespaceName {
+		namespace = sdcfg.SONIC_DEFAULT_NAMESPACE
 	}
+	return isNamespaceDb(namespace, dbName)
 }
 
-// Client package prepare redis clients to all DBs automatically
-func init() {
-	for dbName, dbn := range spb.Target_value {
-		if dbName != "OTHERS" {
-			// DB connector for direct redis operation
-			var redisDb *redis.Client
-
-			redisDb = redis.NewClient(&redis.Options{
-				Network:     "unix",
-				Addr:        sdcfg.GetDbSock(dbName),
-				Password:    "", // no password set
-				DB:          int(dbn),
-				DialTimeout: 0,
-			})
-			Target2RedisDb[dbName] = redisDb
-		}
+// isNamespaceDb tells whether dbName is a DB of the namespace in database config
+func isNamespaceDb(namespace, dbName string) bool {
+	if namespace == sdcfg.SONIC_DEFAULT_NAMESPACE {
+		_, ok := spb.Target_value[dbName]
+		return ok && dbName != "OTHERS"
 	}
+	dbList, err := sdcfg.GetDbListNs(namespace)
+	if err != nil {
+		return false
+	}
+	_, ok := dbList[dbName]
+	return ok
+}
+
+// InitDbConfig switches to the database config file if given, instead of the
+// one by environment variable or the default, and sets up the targets to the
+// DBs in the config again. It is to be called before the DBs are used. The
+// config is checked, all problems found are returned.
+func InitDbConfig(file string) []error {
+	if file != "" {
+		sdcfg.SetDbConfigFile(file)
+	}
+	if err := spb.SetTarget(); err != nil {
+		log.V(1).Infof("Failed to set targets: %v", err)
+	}
+	return sdcfg.ValidateDbConfig()
 }
 
 // gnmiFullPath builds the full path from the prefix and path.
@@ -371,9 +430,9 @@ func gnmiFullPath(prefix, path *gnmipb.Path) *gnmipb.Path {
 	return fullPath
 }
 
-func populateAllDbtablePath(prefix *gnmipb.Path, paths []*gnmipb.Path, pathG2S *map[*gnmipb.Path][]tablePath) error {
+func populateAllDbtablePath(conn *RedisConnManager, prefix *gnmipb.Path, paths []*gnmipb.Path, pathG2S *map[*gnmipb.Path][]tablePath) error {
 	for _, path := range paths {
-		err := populateDbtablePath(prefix, path, pathG2S)
+		err := populateDbtablePath(conn, prefix, path, pathG2S)
 		if err != nil {
 			return err
 		}
@@ -382,16 +441,26 @@ func populateAllDbtablePath(prefix *gnmipb.Path, paths []*gnmipb.Path, pathG2S *
 }
 
 // Populate table path in DB from gnmi path
-func populateDbtablePath(prefix, path *gnmipb.Path, pathG2S *map[*gnmipb.Path][]tablePath) error {
+func populateDbtablePath(conn *RedisConnManager, prefix, path *gnmipb.Path, pathG2S *map[*gnmipb.Path][]tablePath) error {
 	var buffer bytes.Buffer
 	var dbPath string
 	var tblPath tablePath
 
 	target := prefix.GetTarget()
 	// Verify it is a valid db name
-	redisDb, ok := Target2RedisDb[target]
-	if !ok {
-		return fmt.Errorf("Invalid target name %v", target)
+	if _, err := conn.Client(sdcfg.SONIC_DEFAULT_NAMESPACE, target); err != nil {
+		return err
+	}
+	namespace, err := pathNamespace(prefix, path)
+	if err != nil {
+		return err
+	}
+	if namespace == allNamespaces {
+		return expandNamespaces(conn, prefix, path, pathG2S)
+	}
+	redisDb, err := conn.Client(namespace, target)
+	if err != nil {
+		return err
 	}
 
 	fullPath := path
@@ -402,9 +471,12 @@ func populateDbtablePath(prefix, path *gnmipb.Path, pathG2S *map[*gnmipb.Path][]
 	stringSlice := []string{target}
 	separator, _ := GetTableKeySeparator(target)
 	elems := fullPath.GetElem()
+	if len(elems) == 0 {
+		log.V(2).Infof("Invalid db table Path %v", fullPath)
+		return fmt.Errorf("Invalid db table Path %v", fullPath)
+	}
 	if elems != nil {
 		for i, elem := range elems {
-			// TODO: Usage of key field
 			log.V(6).Infof("index %d elem : %#v %#v", i, elem.GetName(), elem.GetKey())
 			if i != 0 {
 				buffer.WriteString(separator)
@@ -417,65 +489,92 @@ func populateDbtablePath(prefix, path *gnmipb.Path, pathG2S *map[*gnmipb.Path][]
 
 	// First lookup the Virtual path to Real path mapping tree
 	// The path from gNMI might not be real db path
-	if tblPaths, err := lookupV2R(stringSlice); err == nil {
-		(*pathG2S)[path] = tblPaths
-		log.V(5).Infof("v2r from %v to %+v ", stringSlice, tblPaths)
-		return nil
-	} else {
-		log.V(5).Infof("v2r lookup failed for %v %v", stringSlice, err)
+	if _, ok := v2rTrie.Find(stringSlice); ok {
+		v := conn.virtualDb(namespace)
+		if err := v.init(); err != nil {
+			return err
+		}
+		if tblPaths, err := lookupV2R(v.loadMaps(), stringSlice); err == nil {
+			for i := range tblPaths {
+				tblPaths[i].namespace = namespace
+			}
+			(*pathG2S)[path] = tblPaths
+			log.V(5).Infof("v2r from %v to %+v ", stringSlice, tblPaths)
+			return nil
+		} else {
+			log.V(5).Infof("v2r lookup failed for %v %v", stringSlice, err)
+		}
+	}
+
+	for _, name := range stringSlice[1:] {
+		if isWildcardElem(name) {
+			return expandDbWildcardPath(conn, prefix, path, namespace, stringSlice[1:], pathG2S)
+		}
 	}
 
+	tblPath.namespace = namespace
 	tblPath.dbName = target
 	tblPath.tableName = stringSlice[1]
-	tblPath.delimitor = separator
+	tblPath.delimitor = tableSeparator(target, tblPath.tableName)
+	schema := getTableSchema(target, tblPath.tableName)
 
-	var mappedKey string
-	if len(stringSlice) > 2 { // tmp, to remove mappedKey
-		mappedKey = stringSlice[2]
+	// Table key given explicitly with keys of the table element
+	explicitKey, err := schema.explicitTableKey(tableElemKeys(elems[0]), tblPath.delimitor)
+	if err != nil {
+		return fmt.Errorf("Invalid db table Path %v: %v", dbPath, err)
 	}
 
 	// The expect real db path could be in one of the formats:
 	// <1> DB Table
-	// <2> DB Table Key
+	// <2> DB Table Key...
 	// <3> DB Table Field
-	// <4> DB Table Key Field
-	// <5> DB Table Key Key Field
-	switch len(stringSlice) {
-	case 2: // only table name provided
+	// <4> DB Table Key... Field
+	// Key may have any number of parts, ex. ROUTE_TABLE:Vrf1:10.0.0.0/24
+	// or BUFFER_PG|Ethernet0|3-4. It could also be given explicitly with
+	// keys of the table element, ex. ROUTE_TABLE[key=10.0.0.0/24]/nexthop
+	// With table schema, key and field are told by the number of key parts.
+	keyElems := stringSlice[2:]
+	switch {
+	case explicitKey != "":
+		if len(keyElems) > 1 {
+			log.V(2).Infof("Invalid db table Path %v", dbPath)
+			return fmt.Errorf("Invalid db table Path %v, only field may follow table with explicit key", dbPath)
+		}
+		tblPath.tableKey = explicitKey
+		if len(keyElems) == 1 {
+			tblPath.field = keyElems[0]
+		}
+	case len(keyElems) > 0 && schema != nil && (schema.Keyless || len(schema.Keys) > 0):
+		nKeys := len(schema.Keys)
+		if len(keyElems) != nKeys && len(keyElems) != nKeys+1 {
+			log.V(2).Infof("Invalid db table Path %v", dbPath)
+			return fmt.Errorf("Invalid db table Path %v, want keys %v and optional field", dbPath, schema.Keys)
+		}
+		tblPath.tableKey = strings.Join(keyElems[:nKeys], tblPath.delimitor)
+		if len(keyElems) > nKeys {
+			tblPath.field = keyElems[nKeys]
+		}
+	case len(keyElems) == 0: // only table name provided
 		res, err := redisDb.Keys(tblPath.tableName + "*").Result()
 		if err != nil || len(res) < 1 {
 			log.V(2).Infof("Invalid db table Path %v %v", target, dbPath)
 			return fmt.Errorf("Failed to find %v %v %v %v", target, dbPath, err, res)
 		}
 		tblPath.tableKey = ""
-	case 3: // Third element could be table key; or field name in which case table name itself is the key too
-		n, err := redisDb.Exists(tblPath.tableName + tblPath.delimitor + mappedKey).Result()
+	default:
+		// All elements could be table key; otherwise the last one is field
+		// name, in which case table name itself is the key if no other element
+		key := strings.Join(keyElems, tblPath.delimitor)
+		n, err := redisDb.Exists(tblPath.tableName + tblPath.delimitor + key).Result()
 		if err != nil {
 			return fmt.Errorf("redis Exists op failed for %v", dbPath)
 		}
 		if n == 1 {
-			tblPath.tableKey = mappedKey
+			tblPath.tableKey = key
 		} else {
-			tblPath.field = mappedKey
+			tblPath.tableKey = strings.Join(keyElems[:len(keyElems)-1], tblPath.delimitor)
+			tblPath.field = keyElems[len(keyElems)-1]
 		}
-	case 4: // Fourth element could part of the table key or field name
-		tblPath.tableKey = mappedKey + tblPath.delimitor + stringSlice[3]
-		// verify whether this key exists
-		key := tblPath.tableName + tblPath.delimitor + tblPath.tableKey
-		n, err := redisDb.Exists(key).Result()
-		if err != nil {
-			return fmt.Errorf("redis Exists op failed for %v", dbPath)
-		}
-		if n != 1 { // Looks like the Fourth slice is not part of the key
-			tblPath.tableKey = mappedKey
-			tblPath.field = stringSlice[3]
-		}
-	case 5: // both third and fourth element are part of table key, fourth element must be field name
-		tblPath.tableKey = mappedKey + tblPath.delimitor + stringSlice[3]
-		tblPath.field = stringSlice[4]
-	default:
-		log.V(2).Infof("Invalid db table Path %v", dbPath)
-		return fmt.Errorf("Invalid db table Path %v", dbPath)
 	}
 
 	var key string
@@ -493,18 +592,211 @@ func populateDbtablePath(prefix, path *gnmipb.Path, pathG2S *map[*gnmipb.Path][]
 	return nil
 }
 
+// isWildcardElem tells whether the path element is gNMI wildcard, "*" for any
+// single element or "..." for any number of elements
+func isWildcardElem(name string) bool {
+	return name == "*" || name == "..."
+}
+
+// matchWildcardPath tells whether the element names match the pattern
+func matchWildcardPath(pattern, names []string) bool {
+	if len(pattern) == 0 {
+		return len(names) == 0
+	}
+	switch pattern[0] {
+	case "...":
+		for i := 0; i <= len(names); i++ {
+			if matchWildcardPath(pattern[1:], names[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(names) > 0 && matchWildcardPath(pattern[1:], names[1:])
+	}
+	return len(names) > 0 && pattern[0] == names[0] && matchWildcardPath(pattern[1:], names[1:])
+}
+
+// wildcardScanMatch returns the redis SCAN pattern of keys which may match
+// the wildcard path, narrowed by its leading concrete elements
+func wildcardScanMatch(target string, pattern []string) string {
+	n := 0
+	for n < len(pattern) && !isWildcardElem(pattern[n]) {
+		n++
+	}
+	// The last one may be a field if followed by "..." matching nothing
+	if n > 1 && n < len(pattern) && pattern[n] == "..." {
+		n--
+	}
+	if n == 0 {
+		return "*"
+	}
+	match := redisGlobEscape(pattern[0])
+	if n > 1 {
+		separator, _ := GetTableKeySeparator(target)
+		delimitor := tableSeparator(target, pattern[0])
+		match += separator + redisGlobEscape(strings.Join(pattern[1:n], delimitor))
+	}
+	return match + "*"
+}
+
+// redisGlobEscape escapes glob special characters of redis key pattern
+func redisGlobEscape(s string) string {
+	var buffer bytes.Buffer
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			buffer.WriteByte('\\')
+		}
+		buffer.WriteRune(r)
+	}
+	return buffer.String()
+}
+
+// expandDbWildcardPath expands path with wildcard elements into concrete
+// paths of the matched tables, keys or fields in redis. Each is added to
+// pathG2S with its own table path. A table or key matched as a whole is not
+// expanded further into its fields. The expansion is done with the data in
+// redis when called, tables, keys and fields added later are not included.
+// It fails if more than WildcardLimit keys or paths are matched.
+// The concrete paths of other namespaces are tagged with the namespace.
+func expandDbWildcardPath(conn *RedisConnManager, prefix, path *gnmipb.Path, namespace string, pattern []string, pathG2S *map[*gnmipb.Path][]tablePath) error {
+	target := prefix.GetTarget()
+	redisDb, err := conn.Client(namespace, target)
+	if err != nil {
+		return err
+	}
+	separator, _ := GetTableKeySeparator(target)
+	for _, elem := range prefix.GetElem() {
+		if isWildcardElem(elem.GetName()) {
+			return fmt.Errorf("Wildcard in prefix is not supported: %v", prefix)
+		}
+	}
+	prefixLen := len(prefix.GetElem())
+
+	tooMany := fmt.Errorf("%v %v matches more than %d entries", target, strings.Join(pattern, separator), WildcardLimit)
+	keyPattern := wildcardScanMatch(target, pattern)
+	var dbkeys []string
+	iter := redisDb.Scan(0, keyPattern, int64(WildcardLimit)).Iterator()
+	seen := make(map[string]bool)
+	for iter.Next() {
+		if seen[iter.Val()] { // SCAN may return a key more than once
+			continue
+		}
+		if len(dbkeys) == WildcardLimit {
+			return tooMany
+		}
+		seen[iter.Val()] = true
+		dbkeys = append(dbkeys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("redis Scan failed for %v, pattern %s %v", target, keyPattern, err)
+	}
+	sort.Strings(dbkeys)
+
+	var found int
+	addPath := func(names []string, tblPath tablePath) {
+		concrete := &gnmipb.Path{Origin: path.GetOrigin(), Target: path.GetTarget()}
+		for _, name := range names[prefixLen:] {
+			concrete.Elem = append(concrete.Elem, &gnmipb.PathElem{Name: name})
+		}
+		if namespace != sdcfg.SONIC_DEFAULT_NAMESPACE {
+			if prefixLen == 0 {
+				concrete.Elem = namespaceElems(concrete.Elem, namespace)
+			} else {
+				concrete.Origin = namespace
+			}
+		}
+		tblPath.namespace = namespace
+		tblPath.expanded = true
+		(*pathG2S)[concrete] = []tablePath{tblPath}
+		found++
+		log.V(5).Infof("%v expanded to %v tablePath %+v", pattern, names, tblPath)
+	}
+
+	// Whole tables matched
+	tables := make(map[string]bool)
+	for _, dbkey := range dbkeys {
+		table := strings.SplitN(dbkey, separator, 2)[0]
+		if _, ok := tables[table]; ok {
+			continue
+		}
+		tables[table] = matchWildcardPath(pattern, []string{table})
+		if tables[table] {
+			addPath([]string{table}, tablePath{
+				dbName:    target,
+				tableName: table,
+				delimitor: separator,
+			})
+		}
+	}
+
+	for _, dbkey := range dbkeys {
+		if found > WildcardLimit {
+			return tooMany
+		}
+		names := strings.SplitN(dbkey, separator, 2)
+		if tables[names[0]] { // matched as whole table
+			continue
+		}
+		if !isWildcardElem(pattern[0]) && names[0] != pattern[0] {
+			continue
+		}
+		tblPath := tablePath{
+			dbName:    target,
+			tableName: names[0],
+			delimitor: tableSeparator(target, names[0]),
+		}
+		if len(names) > 1 {
+			tblPath.tableKey = names[1]
+			// Split key into parts by schema if known, ex. oid:0x1000000000039
+			// of COUNTERS table is one part
+			n := -1
+			if schema := getTableSchema(target, names[0]); schema != nil && len(schema.Keys) > 0 {
+				n = len(schema.Keys)
+			}
+			names = append(names[:1], strings.SplitN(names[1], tblPath.delimitor, n)...)
+			if matchWildcardPath(pattern, names) {
+				addPath(names, tblPath)
+				continue
+			}
+		}
+		fields, err := redisDb.HKeys(dbkey).Result()
+		if err != nil {
+			log.V(2).Infof("redis HKeys failed for %v %v: %v", target, dbkey, err)
+			continue
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			fieldNames := append(append([]string{}, names...), field)
+			if matchWildcardPath(pattern, fieldNames) {
+				fieldPath := tblPath
+				fieldPath.field = field
+				addPath(fieldNames, fieldPath)
+			}
+		}
+	}
+	if found == 0 {
+		return fmt.Errorf("No valid entry found on %v %v", target, strings.Join(pattern, separator))
+	}
+	if found > WildcardLimit {
+		return tooMany
+	}
+	return nil
+}
+
 // makeJSON renders the database Key op value_pairs to map[string]interface{} for JSON marshall.
-func makeJSON_redis(msi *map[string]interface{}, key *string, op *string, mfv map[string]string) error {
+// Values are rendered by their types in table schema if given.
+func makeJSON_redis(msi *map[string]interface{}, key *string, op *string, mfv map[string]string, schema *tableSchema) error {
 	if key == nil && op == nil {
 		for f, v := range mfv {
-			(*msi)[f] = v
+			(*msi)[f] = schema.renderField(f, v)
 		}
 		return nil
 	}
 
 	fp := map[string]interface{}{}
 	for f, v := range mfv {
-		fp[f] = v
+		fp[f] = schema.renderField(f, v)
 	}
 
 	if key == nil {
@@ -536,18 +828,21 @@ func emitJSON(v *map[string]interface{}) ([]byte, error) {
 // which may be marshaled to JSON format
 // If only table name provided in the tablePath, find all keys in the table, otherwise
 // Use tableName + tableKey as key to get all field value paires
-func tableData2Msi(tblPath *tablePath, useKey bool, op *string, msi *map[string]interface{}) error {
-	redisDb := Target2RedisDb[tblPath.dbName]
+func tableData2Msi(conn *RedisConnManager, tblPath *tablePath, useKey bool, op *string, msi *map[string]interface{}) error {
+	if isVirtualTable(tblPath.tableName) {
+		return virtualTableData2Msi(conn, tblPath, msi)
+	}
+	redisDb := conn.tableDb(tblPath)
 
 	var pattern string
 	var dbkeys []string
 	var err error
 	var fv map[string]string
 
+	schema := getTableSchema(tblPath.dbName, tblPath.tableName)
 	//Only table name provided
 	if tblPath.tableKey == "" {
-		// tables in COUNTERS_DB other than COUNTERS table doesn't have keys
-		if tblPath.dbName == "COUNTERS_DB" && tblPath.tableName != "COUNTERS" {
+		if isKeylessTable(tblPath.dbName, tblPath.tableName) {
 			pattern = tblPath.tableName
 		} else {
 			pattern = tblPath.tableName + tblPath.delimitor + "*"
@@ -571,7 +866,7 @@ func tableData2Msi(tblPath *tablePath, useKey bool, op *string, msi *map[string]
 			return nil
 		}
 		fv = map[string]string{tblPath.jsonField: val}
-		makeJSON_redis(msi, &tblPath.jsonTableKey, op, fv)
+		makeJSON_redis(msi, &tblPath.jsonTableKey, op, fv, schema)
 		log.V(6).Infof("Added json key %v fv %v ", tblPath.jsonTableKey, fv)
 		return nil
 	}
@@ -584,15 +879,15 @@ func tableData2Msi(tblPath *tablePath, useKey bool, op *string, msi *map[string]
 		}
 
 		if tblPath.jsonTableKey != "" { // If jsonTableKey was prepared, use it
-			err = makeJSON_redis(msi, &tblPath.jsonTableKey, op, fv)
+			err = makeJSON_redis(msi, &tblPath.jsonTableKey, op, fv, schema)
 		} else if (tblPath.tableKey != "" && !useKey) || tblPath.tableName == dbkey {
-			err = makeJSON_redis(msi, nil, op, fv)
+			err = makeJSON_redis(msi, nil, op, fv, schema)
 		} else {
 			var key string
 			// Split dbkey string into two parts and second part is key in table
 			keys := strings.SplitN(dbkey, tblPath.delimitor, 2)
 			key = keys[1]
-			err = makeJSON_redis(msi, &key, op, fv)
+			err = makeJSON_redis(msi, &key, op, fv, schema)
 		}
 		if err != nil {
 			log.V(2).Infof("makeJSON err %s for fv %v", err, fv)
@@ -615,11 +910,11 @@ func msi2TypedValue(msi map[string]interface{}) (*gnmipb.TypedValue, error) {
 		}}, nil
 }
 
-func tableData2TypedValue(tblPaths []tablePath, op *string) (*gnmipb.TypedValue, error) {
+func tableData2TypedValue(conn *RedisConnManager, tblPaths []tablePath, op *string) (*gnmipb.TypedValue, error) {
 	var useKey bool
 	msi := make(map[string]interface{})
 	for _, tblPath := range tblPaths {
-		redisDb := Target2RedisDb[tblPath.dbName]
+		redisDb := conn.tableDb(&tblPath)
 
 		if tblPath.jsonField == "" { // Not asked to include field in json value, which means not wildcard query
 			// table path includes table, key and field
@@ -627,6 +922,16 @@ func tableData2TypedValue(tblPaths []tablePath, op *string) (*gnmipb.TypedValue,
 				if len(tblPaths) != 1 {
 					log.V(2).Infof("WARNING: more than one path exists for field granularity query: %v", tblPaths)
 				}
+				if isVirtualTable(tblPath.tableName) {
+					val, err := virtualTableField(conn, &tblPath)
+					if err != nil {
+						return nil, err
+					}
+					return &gnmipb.TypedValue{
+						Value: &gnmipb.TypedValue_StringVal{
+							StringVal: val,
+						}}, nil
+				}
 				var key string
 				if tblPath.tableKey != "" {
 					key = tblPath.tableName + tblPath.delimitor + tblPath.tableKey
@@ -640,14 +945,11 @@ func tableData2TypedValue(tblPaths []tablePath, op *string) (*gnmipb.TypedValue,
 					return nil, err
 				}
 				// TODO: support multiple table paths
-				return &gnmipb.TypedValue{
-					Value: &gnmipb.TypedValue_StringVal{
-						StringVal: val,
-					}}, nil
+				return getTableSchema(tblPath.dbName, tblPath.tableName).fieldTypedValue(tblPath.field, val), nil
 			}
 		}
 
-		err := tableData2Msi(&tblPath, useKey, nil, &msi)
+		err := tableData2Msi(conn, &tblPath, useKey, nil, &msi)
 		if err != nil {
 			return nil, err
 		}
@@ -664,6 +966,187 @@ func enqueFatalMsg(c *DbClient, msg string) {
 	})
 }
 
+// waitRedisRecovery blocks until redis of the DB answers PING again, retrying
+// with exponential backoff. It returns false if the client is stopped meanwhile.
+func waitRedisRecovery(c *DbClient, namespace, dbName string) bool {
+	redisDb, _ := c.conn.Client(namespace, dbName)
+	backoff := redisRetryMin
+	for {
+		select {
+		case <-c.channel:
+			return false
+		case <-time.After(backoff):
+		}
+		if _, err := redisDb.Ping().Result(); err == nil {
+			log.V(1).Infof("Redis connection to %v recovered for %v", dbName, c)
+			return true
+		}
+		backoff *= 2
+		if backoff > redisRetryMax {
+			backoff = redisRetryMax
+		}
+	}
+}
+
+// waitTblPathsRecovery waits for redis of the DBs of the table paths, which
+// may be in several namespaces for the aggregate view of virtual path.
+func waitTblPathsRecovery(c *DbClient, tblPaths []tablePath) bool {
+	waited := make(map[string]bool)
+	for _, tblPath := range tblPaths {
+		if waited[tblPath.namespace] {
+			continue
+		}
+		waited[tblPath.namespace] = true
+		if !waitRedisRecovery(c, tblPath.namespace, tblPath.dbName) {
+			return false
+		}
+	}
+	return true
+}
+
+// resyncStart is called by subscription routine which lost redis connection.
+// The clients are informed of the gap with an update of RedisGapPath if asked.
+func (c *DbClient) resyncStart(gnmiPath *gnmipb.Path) {
+	c.mu.Lock()
+	c.resyncing++
+	c.mu.Unlock()
+	if NotifyRedisGap {
+		c.q.Put(Value{
+			&spb.Value{
+				Prefix:    c.prefix,
+				Path:      RedisGapPath,
+				Timestamp: time.Now().UnixNano(),
+				Val: &gnmipb.TypedValue{
+					Value: &gnmipb.TypedValue_StringVal{StringVal: gnmiPathString(gnmiPath)},
+				},
+			},
+		})
+	}
+}
+
+// gnmiPathString returns path in string form, ex. /COUNTERS/Ethernet68[k=v]
+func gnmiPathString(path *gnmipb.Path) string {
+	var b strings.Builder
+	for _, elem := range path.GetElem() {
+		b.WriteString("/" + elem.GetName())
+		keys := make([]string, 0, len(elem.GetKey()))
+		for k := range elem.GetKey() {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			b.WriteString("[" + k + "=" + elem.GetKey()[k] + "]")
+		}
+	}
+	if b.Len() == 0 {
+		return "/"
+	}
+	return b.String()
+}
+
+// resyncDone is called after the subscription routine has sent full data of
+// its path again. A fresh sync_response is sent once all routines resynced.
+func (c *DbClient) resyncDone() {
+	c.mu.Lock()
+	c.resyncing--
+	n := c.resyncing
+	c.mu.Unlock()
+	if n == 0 {
+		c.q.Put(Value{
+			&spb.Value{
+				Timestamp:    time.Now().UnixNano(),
+				SyncResponse: true,
+			},
+		})
+		log.V(2).Infof("%v Resynced", c.pathG2S)
+	}
+}
+
+// v2rUpdate returns channel closed upon change of the name maps which virtual
+// path is translated with, those of all namespaces for the aggregate view.
+// It is nil for targets without virtual path.
+func (c *DbClient) v2rUpdate(gnmiPath *gnmipb.Path) <-chan struct{} {
+	if c.dbPrefix.GetTarget() != "COUNTERS_DB" {
+		return nil
+	}
+	namespace, err := pathNamespace(c.dbPrefix, gnmiPath)
+	if err != nil {
+		return nil
+	}
+	if namespace == allNamespaces {
+		return c.conn.mapsUpdateAll(c.channel)
+	}
+	return c.conn.virtualDb(namespace).mapsUpdate()
+}
+
+// retranslate translates gnmiPath to table paths again, for virtual path
+// they may have changed with the name maps.
+func (c *DbClient) retranslate(gnmiPath *gnmipb.Path) ([]tablePath, error) {
+	pathG2S := make(map[*gnmipb.Path][]tablePath)
+	if err := populateDbtablePath(c.conn, c.dbPrefix, gnmiPath, &pathG2S); err != nil {
+		return nil, err
+	}
+	return pathG2S[gnmiPath], nil
+}
+
+// childPath returns a copy of path with name appended as the last element
+func childPath(path *gnmipb.Path, name string) *gnmipb.Path {
+	child := &gnmipb.Path{Origin: path.GetOrigin(), Target: path.GetTarget()}
+	if path.GetElem() != nil {
+		child.Elem = append(append([]*gnmipb.PathElem{}, path.GetElem()...), &gnmipb.PathElem{Name: name})
+	} else {
+		child.Element = append(append([]string{}, path.GetElement()...), name)
+	}
+	return child
+}
+
+// enqueRemoved sends delete for the data of table paths no longer in newPaths.
+// The deleted path is gnmiPath appended with the key in json data, or
+// gnmiPath itself if data is not keyed.
+func (c *DbClient) enqueRemoved(gnmiPath *gnmipb.Path, oldPaths, newPaths []tablePath) {
+	remain := make(map[string]bool)
+	for _, tblPath := range newPaths {
+		remain[tblPath.jsonTableKey] = true
+	}
+	deleted := make(map[string]bool)
+	for _, tblPath := range oldPaths {
+		key := tblPath.jsonTableKey
+		if remain[key] || deleted[key] {
+			continue
+		}
+		deleted[key] = true
+		path := gnmiPath
+		if key != "" {
+			path = childPath(gnmiPath, key)
+		}
+		log.V(2).Infof("%v removed from %v", key, gnmiPath)
+		c.q.Put(Value{
+			&spb.Value{
+				Prefix:    c.prefix,
+				Path:      path,
+				Timestamp: time.Now().UnixNano(),
+			},
+		})
+	}
+}
+
+// sameTblPaths tells whether the two table path lists have same members
+func sameTblPaths(a, b []tablePath) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[tablePath]bool)
+	for _, tblPath := range a {
+		set[tblPath] = true
+	}
+	for _, tblPath := range b {
+		if !set[tblPath] {
+			return false
+		}
+	}
+	return true
+}
+
 // for subscribe request with granularity of table field, the value is fetched periodically.
 // Upon value change, it will be put to queue for furhter notification
 func dbFieldMultiSubscribe(gnmiPath *gnmipb.Path, c *DbClient) {
@@ -677,14 +1160,36 @@ func dbFieldMultiSubscribe(gnmiPath *gnmipb.Path, c *DbClient) {
 		path2ValueMap[tblPath] = ""
 	}
 	synced := bool(false)
+	// Set after redis connection recovered, until data has been sent again
+	resync := bool(false)
+	updated := c.v2rUpdate(gnmiPath)
 
 	for {
 		select {
 		case <-c.channel:
 			log.V(1).Infof("Stopping dbFieldMultiSubscribe routine for Client %s ", c)
 			return
+		case <-updated:
+			// Ports may be added or removed
+			updated = c.v2rUpdate(gnmiPath)
+			newPaths, err := c.retranslate(gnmiPath)
+			if err != nil {
+				log.V(2).Infof("Failed to translate %v again: %v", gnmiPath, err)
+			}
+			if sameTblPaths(tblPaths, newPaths) {
+				continue
+			}
+			c.enqueRemoved(gnmiPath, tblPaths, newPaths)
+			newValueMap := make(map[tablePath]string)
+			for _, tblPath := range newPaths {
+				newValueMap[tblPath] = path2ValueMap[tblPath]
+			}
+			tblPaths, path2ValueMap = newPaths, newValueMap
 		default:
 			msi := make(map[string]interface{})
+			// Saved in path2ValueMap only after sent, a pass may be dropped
+			newValues := make(map[tablePath]string)
+			var lost, pending bool
 			for _, tblPath := range tblPaths {
 				var key string
 				if tblPath.tableKey != "" {
@@ -693,32 +1198,52 @@ func dbFieldMultiSubscribe(gnmiPath *gnmipb.Path, c *DbClient) {
 					key = tblPath.tableName
 				}
 				// run redis get directly for field value
-				redisDb := Target2RedisDb[tblPath.dbName]
+				redisDb := c.conn.tableDb(&tblPath)
 				val, err := redisDb.HGet(key, tblPath.field).Result()
 				if err == redis.Nil {
 					if tblPath.jsonField != "" {
 						// ignore non-existing field which was derived from virtual path
 						continue
 					}
+					if resync {
+						// Data may not be populated yet after redis restart
+						pending = true
+						break
+					}
 					log.V(2).Infof("%v doesn't exist with key %v in db", tblPath.field, key)
 					enqueFatalMsg(c, fmt.Sprintf("%v doesn't exist with key %v in db", tblPath.field, key))
 					return
 				}
 				if err != nil {
-					log.V(1).Infof(" redis HGet error on %v with key %v", tblPath.field, key)
-					enqueFatalMsg(c, fmt.Sprintf(" redis HGet error on %v with key %v", tblPath.field, key))
-					return
+					log.V(1).Infof(" redis HGet error on %v with key %v: %v", tblPath.field, key, err)
+					lost = true
+					break
 				}
 				if val == path2ValueMap[tblPath] {
 					continue
 				}
-				path2ValueMap[tblPath] = val
+				newValues[tblPath] = val
 				fv := map[string]string{tblPath.jsonField: val}
 				msi[tblPath.jsonTableKey] = fv
 				log.V(6).Infof("new value %v for %v", val, tblPath)
 			}
 
-			if len(msi) != 0 {
+			if lost {
+				if !resync {
+					c.resyncStart(gnmiPath)
+					resync = true
+				}
+				if !waitTblPathsRecovery(c, tblPaths) {
+					return
+				}
+				// Send all values again
+				for tblPath := range path2ValueMap {
+					path2ValueMap[tblPath] = ""
+				}
+				continue
+			}
+
+			if len(msi) != 0 && !pending {
 				val, err := msi2TypedValue(msi)
 				if err != nil {
 					enqueFatalMsg(c, err.Error())
@@ -736,11 +1261,18 @@ func dbFieldMultiSubscribe(gnmiPath *gnmipb.Path, c *DbClient) {
 					log.V(1).Infof("Queue error:  %v", err)
 					return
 				}
+				for tblPath, val := range newValues {
+					path2ValueMap[tblPath] = val
+				}
 
 				if !synced {
 					c.synced.Done()
 					synced = true
 				}
+				if resync {
+					c.resyncDone()
+					resync = false
+				}
 			}
 			// check again after 200 millisends, to use configured variable
 			time.Sleep(time.Millisecond * 200)
@@ -756,7 +1288,7 @@ func dbFieldSubscribe(gnmiPath *gnmipb.Path, c *DbClient) {
 	tblPaths := c.pathG2S[gnmiPath]
 	tblPath := tblPaths[0]
 	// run redis get directly for field value
-	redisDb := Target2RedisDb[tblPath.dbName]
+	redisDb := c.conn.tableDb(&tblPath)
 
 	var key string
 	if tblPath.tableKey != "" {
@@ -766,42 +1298,72 @@ func dbFieldSubscribe(gnmiPath *gnmipb.Path, c *DbClient) {
 	}
 
 	var val string
+	synced := bool(false)
+	// Set after redis connection recovered, until the value has been sent again
+	resync := bool(false)
+	updated := c.v2rUpdate(gnmiPath)
 	for {
 		select {
 		case <-c.channel:
 			log.V(1).Infof("Stopping dbFieldSubscribe routine for Client %s ", c)
 			return
+		case <-updated:
+			// oid of the port may change, ex. after orchagent restart
+			updated = c.v2rUpdate(gnmiPath)
+			newPaths, err := c.retranslate(gnmiPath)
+			if err != nil || len(newPaths) != 1 {
+				log.V(2).Infof("Failed to translate %v again: %v", gnmiPath, err)
+				continue
+			}
+			tblPath = newPaths[0]
+			redisDb = c.conn.tableDb(&tblPath)
+			if tblPath.tableKey != "" {
+				key = tblPath.tableName + tblPath.delimitor + tblPath.tableKey
+			} else {
+				key = tblPath.tableName
+			}
 		default:
 			newVal, err := redisDb.HGet(key, tblPath.field).Result()
+			if err == redis.Nil && resync {
+				// Data may not be populated yet after redis restart
+				time.Sleep(time.Millisecond * 200)
+				continue
+			}
 			if err == redis.Nil {
 				log.V(2).Infof("%v doesn't exist with key %v in db", tblPath.field, key)
 				enqueFatalMsg(c, fmt.Sprintf("%v doesn't exist with key %v in db", tblPath.field, key))
 				return
 			}
 			if err != nil {
-				log.V(1).Infof(" redis HGet error on %v with key %v", tblPath.field, key)
-				enqueFatalMsg(c, fmt.Sprintf(" redis HGet error on %v with key %v", tblPath.field, key))
-				return
+				log.V(1).Infof(" redis HGet error on %v with key %v: %v", tblPath.field, key, err)
+				if !resync {
+					c.resyncStart(gnmiPath)
+					resync = true
+				}
+				if !waitRedisRecovery(c, tblPath.namespace, tblPath.dbName) {
+					return
+				}
+				continue
 			}
-			if newVal != val {
+			if newVal != val || resync {
 				spbv := &spb.Value{
 					Prefix:    c.prefix,
 					Path:      gnmiPath,
 					Timestamp: time.Now().UnixNano(),
-					Val: &gnmipb.TypedValue{
-						Value: &gnmipb.TypedValue_StringVal{
-							StringVal: newVal,
-						},
-					},
+					Val:       getTableSchema(tblPath.dbName, tblPath.tableName).fieldTypedValue(tblPath.field, newVal),
 				}
 
 				if err = c.q.Put(Value{spbv}); err != nil {
 					log.V(1).Infof("Queue error:  %v", err)
 					return
 				}
-				// If old val is empty, assumming this is initial sync
-				if val == "" {
+				if !synced {
 					c.synced.Done()
+					synced = true
+				}
+				if resync {
+					c.resyncDone()
+					resync = false
 				}
 				val = newVal
 			}
@@ -811,10 +1373,100 @@ func dbFieldSubscribe(gnmiPath *gnmipb.Path, c *DbClient) {
 	}
 }
 
+// for subscribe request on virtual table, which is not backed by redis keys,
+// the value is computed periodically. Upon value change, it will be put to
+// queue for further notification
+func dbVirtualTableSubscribe(gnmiPath *gnmipb.Path, c *DbClient) {
+	defer c.w.Done()
+
+	tblPaths := c.pathG2S[gnmiPath]
+	var val *gnmipb.TypedValue
+	synced := bool(false)
+	// Set after redis connection recovered, until the value has been sent again
+	resync := bool(false)
+	updated := c.v2rUpdate(gnmiPath)
+	for {
+		select {
+		case <-c.channel:
+			log.V(1).Infof("Stopping dbVirtualTableSubscribe routine for Client %s ", c)
+			return
+		case <-updated:
+			// port channel or vlan may be added or removed
+			updated = c.v2rUpdate(gnmiPath)
+			newPaths, err := c.retranslate(gnmiPath)
+			if err != nil {
+				log.V(2).Infof("Failed to translate %v again: %v", gnmiPath, err)
+				continue
+			}
+			if !sameTblPaths(tblPaths, newPaths) {
+				c.enqueRemoved(gnmiPath, tblPaths, newPaths)
+				tblPaths = newPaths
+			}
+		default:
+			newVal, err := tableData2TypedValue(c.conn, tblPaths, nil)
+			if isRatesNotReady(err) {
+				time.Sleep(virtualTablePollInterval)
+				continue
+			}
+			if err != nil {
+				dbName := c.dbPrefix.GetTarget()
+				redisDb, _ := c.conn.Client(sdcfg.SONIC_DEFAULT_NAMESPACE, dbName)
+				if _, perr := redisDb.Ping().Result(); perr == nil {
+					log.V(2).Infof("Failed to get %v: %v", gnmiPath, err)
+					if !synced {
+						enqueFatalMsg(c, fmt.Sprintf("Failed to get %v: %v", gnmiPath, err))
+						return
+					}
+					time.Sleep(virtualTablePollInterval)
+					continue
+				}
+				log.V(1).Infof("redis error on %v: %v", gnmiPath, err)
+				if !resync {
+					c.resyncStart(gnmiPath)
+					resync = true
+				}
+				if !waitRedisRecovery(c, sdcfg.SONIC_DEFAULT_NAMESPACE, dbName) {
+					return
+				}
+				val = nil
+				continue
+			}
+			if !proto.Equal(newVal, val) || resync {
+				spbv := &spb.Value{
+					Prefix:    c.prefix,
+					Path:      gnmiPath,
+					Timestamp: time.Now().UnixNano(),
+					Val:       newVal,
+				}
+				if err = c.q.Put(Value{spbv}); err != nil {
+					log.V(1).Infof("Queue error:  %v", err)
+					return
+				}
+				if !synced {
+					c.synced.Done()
+					synced = true
+				}
+				if resync {
+					c.resyncDone()
+					resync = false
+				}
+				val = newVal
+			}
+			time.Sleep(virtualTablePollInterval)
+		}
+	}
+}
+
 type redisSubData struct {
 	tblPath   tablePath
 	pubsub    *redis.PubSub
 	prefixLen int
+	// closed when the subscription is set up again after redis recovery
+	stop chan struct{}
+	// informs dbTableKeySubscribe that redis connection was lost
+	lost chan struct{}
+	// done when the routine exits
+	routines *sync.WaitGroup
 }
 
 // TODO: For delete operation, the exact content returned is to be clarified.
@@ -823,6 +1475,16 @@ func dbSingleTableKeySubscribe(rsd redisSubData, c *DbClient, msiOut *map[string
 	pubsub := rsd.pubsub
 	prefixLen := rsd.prefixLen
 	msi := make(map[string]interface{})
+	defer rsd.routines.Done()
+
+	// Redis connection lost, dbTableKeySubscribe will set up the subscription again
+	connLost := func(err error) {
+		log.V(1).Infof("Redis connection lost for %+v: %v", tblPath, err)
+		select {
+		case rsd.lost <- struct{}{}:
+		default:
+		}
+	}
 
 	for {
 		select {
@@ -836,10 +1498,15 @@ func dbSingleTableKeySubscribe(rsd redisSubData, c *DbClient, msiOut *map[string
 					}
 				}
 				log.V(2).Infof("pubsub.ReceiveTimeout err %v", err)
-				continue
+				connLost(err)
+				return
 			}
 			newMsi := make(map[string]interface{})
-			subscr := msgi.(*redis.Message)
+			subscr, ok := msgi.(*redis.Message)
+			if !ok {
+				log.V(2).Infof("Unexpected psubscribe message %v", msgi)
+				continue
+			}
 
 			// TODO: support for "Delete []*Path"
 			if subscr.Payload == "del" || subscr.Payload == "hdel" {
@@ -858,9 +1525,9 @@ func dbSingleTableKeySubscribe(rsd redisSubData, c *DbClient, msiOut *map[string
 			} else if subscr.Payload == "hset" {
 				//op := "SET"
 				if tblPath.tableKey != "" {
-					err = tableData2Msi(&tblPath, false, nil, &newMsi)
+					err = tableData2Msi(c.conn, &tblPath, false, nil, &newMsi)
 					if err != nil {
-						enqueFatalMsg(c, err.Error())
+						connLost(err)
 						return
 					}
 				} else {
@@ -870,9 +1537,9 @@ func dbSingleTableKeySubscribe(rsd redisSubData, c *DbClient, msiOut *map[string
 						continue
 					}
 					tblPath.tableKey = subscr.Channel[prefixLen:]
-					err = tableData2Msi(&tblPath, false, nil, &newMsi)
+					err = tableData2Msi(c.conn, &tblPath, false, nil, &newMsi)
 					if err != nil {
-						enqueFatalMsg(c, err.Error())
+						connLost(err)
 						return
 					}
 				}
@@ -891,6 +1558,9 @@ func dbSingleTableKeySubscribe(rsd redisSubData, c *DbClient, msiOut *map[string
 			}
 			c.mu.Unlock()
 
+		case <-rsd.stop:
+			log.V(2).Infof("Stopping dbSingleTableKeySubscribe routine for %+v to resubscribe", tblPath)
+			return
 		case <-c.channel:
 			log.V(2).Infof("Stopping dbSingleTableKeySubscribe routine for %+v", tblPath)
 			return
@@ -898,18 +1568,25 @@ func dbSingleTableKeySubscribe(rsd redisSubData, c *DbClient, msiOut *map[string
 	}
 }
 
-func dbTableKeySubscribe(gnmiPath *gnmipb.Path, c *DbClient) {
-	defer c.w.Done()
-
-	tblPaths := c.pathG2S[gnmiPath]
-	msi := make(map[string]interface{})
+func closePubSubs(pubsubs []*redis.PubSub) {
+	for _, pubsub := range pubsubs {
+		pubsub.Close()
+	}
+}
 
+// subscribeTblPaths subscribes to keyspace notification of the table paths,
+// reads their current data to msi and starts dbSingleTableKeySubscribe routines
+// which stop upon closing of stop channel, added to routines. Subscription is
+// done before reading data so no change in between is missed.
+func subscribeTblPaths(tblPaths []tablePath, c *DbClient, msi *map[string]interface{}, stop, lost chan struct{}, routines *sync.WaitGroup) ([]*redis.PubSub, error) {
+	var pubsubs []*redis.PubSub
 	for _, tblPath := range tblPaths {
+		redisDb := c.conn.tableDb(&tblPath)
 		// Subscribe to keyspace notification
-		pattern := "__keyspace@" + strconv.Itoa(int(spb.Target_value[tblPath.dbName])) + "__:"
+		pattern := "__keyspace@" + strconv.Itoa(redisDb.Options().DB) + "__:"
 		pattern += tblPath.tableName
-		if tblPath.dbName == "COUNTERS_DB" && tblPath.tableName != "COUNTERS" {
-			// tables in COUNTERS_DB other than COUNTERS don't have keys, skip delimitor
+		if isKeylessTable(tblPath.dbName, tblPath.tableName) {
+			// tables without keys, skip delimitor
 		} else {
 			pattern += tblPath.delimitor
 		}
@@ -922,92 +1599,186 @@ func dbTableKeySubscribe(gnmiPath *gnmipb.Path, c *DbClient) {
 			prefixLen = len(pattern)
 			pattern += "*"
 		}
-		redisDb := Target2RedisDb[tblPath.dbName]
 		pubsub := redisDb.PSubscribe(pattern)
-		defer pubsub.Close()
+		pubsubs = append(pubsubs, pubsub)
 
 		msgi, err := pubsub.ReceiveTimeout(time.Second)
 		if err != nil {
 			log.V(1).Infof("psubscribe to %s failed for %v", pattern, tblPath)
-			enqueFatalMsg(c, fmt.Sprintf("psubscribe to %s failed for %v", pattern, tblPath))
-			return
+			closePubSubs(pubsubs)
+			return nil, fmt.Errorf("psubscribe to %s failed for %v", pattern, tblPath)
 		}
-		subscr := msgi.(*redis.Subscription)
-		if subscr.Channel != pattern {
+		subscr, ok := msgi.(*redis.Subscription)
+		if !ok || subscr.Channel != pattern {
 			log.V(1).Infof("psubscribe to %s failed for %v", pattern, tblPath)
-			enqueFatalMsg(c, fmt.Sprintf("psubscribe to %s failed for %v", pattern, tblPath))
-			return
+			closePubSubs(pubsubs)
+			return nil, fmt.Errorf("psubscribe to %s failed for %v", pattern, tblPath)
 		}
 		log.V(2).Infof("Psubscribe succeeded for %v: %v", tblPath, subscr)
 
-		err = tableData2Msi(&tblPath, false, nil, &msi)
+		c.mu.Lock()
+		err = tableData2Msi(c.conn, &tblPath, false, nil, msi)
+		c.mu.Unlock()
 		if err != nil {
-			enqueFatalMsg(c, err.Error())
-			return
+			closePubSubs(pubsubs)
+			return nil, err
 		}
 		rsd := redisSubData{
 			tblPath:   tblPath,
 			pubsub:    pubsub,
 			prefixLen: prefixLen,
+			stop:      stop,
+			lost:      lost,
+			routines:  routines,
 		}
-		go dbSingleTableKeySubscribe(rsd, c, &msi)
+		routines.Add(1)
+		go dbSingleTableKeySubscribe(rsd, c, msi)
 	}
+	return pubsubs, nil
+}
 
+// enqueMsi puts the data accumulated in msi to queue and empties msi
+func enqueMsi(gnmiPath *gnmipb.Path, c *DbClient, msi map[string]interface{}, prefix *gnmipb.Path) error {
+	c.mu.Lock()
 	val, err := msi2TypedValue(msi)
+	for k := range msi {
+		delete(msi, k)
+	}
+	c.mu.Unlock()
 	if err != nil {
-		enqueFatalMsg(c, err.Error())
-		return
+		return err
 	}
-	var spbv *spb.Value
-	spbv = &spb.Value{
-		Prefix:    c.prefix,
+	spbv := &spb.Value{
+		Prefix:    prefix,
 		Path:      gnmiPath,
 		Timestamp: time.Now().UnixNano(),
 		Val:       val,
 	}
-	if err = c.q.Put(Value{spbv}); err != nil {
+	log.V(5).Infof("dbTableKeySubscribe enque: %v", spbv)
+	return c.q.Put(Value{spbv})
+}
+
+// stopTblPaths stops the dbSingleTableKeySubscribe routines and waits for them
+// to exit, so none of them writes to msi after it.
+func stopTblPaths(pubsubs []*redis.PubSub, stop chan struct{}, routines *sync.WaitGroup) {
+	close(stop)
+	closePubSubs(pubsubs)
+	routines.Wait()
+}
+
+// resubscribeTblPaths clears msi and sets up the subscription of tblPaths again
+// with new stop and lost channels. The routines of the previous subscription
+// must have been stopped with stopTblPaths.
+func resubscribeTblPaths(tblPaths []tablePath, c *DbClient, msi *map[string]interface{}, routines *sync.WaitGroup) ([]*redis.PubSub, chan struct{}, chan struct{}, error) {
+	c.mu.Lock()
+	for k := range *msi {
+		delete(*msi, k)
+	}
+	c.mu.Unlock()
+	stop := make(chan struct{})
+	lost := make(chan struct{}, 1)
+	pubsubs, err := subscribeTblPaths(tblPaths, c, msi, stop, lost, routines)
+	if err != nil {
+		// Stop routines already started, leave a fresh stop channel to caller
+		close(stop)
+		routines.Wait()
+		stop = make(chan struct{})
+	}
+	return pubsubs, stop, lost, err
+}
+
+func dbTableKeySubscribe(gnmiPath *gnmipb.Path, c *DbClient) {
+	defer c.w.Done()
+
+	tblPaths := c.pathG2S[gnmiPath]
+	msi := make(map[string]interface{})
+	updated := c.v2rUpdate(gnmiPath)
+
+	// stop and lost are renewed each time the subscription is set up
+	stop := make(chan struct{})
+	lost := make(chan struct{}, 1)
+	var routines sync.WaitGroup
+	pubsubs, err := subscribeTblPaths(tblPaths, c, &msi, stop, lost, &routines)
+	if err != nil {
+		close(stop)
+		routines.Wait()
+		enqueFatalMsg(c, err.Error())
+		return
+	}
+	defer func() {
+		stopTblPaths(pubsubs, stop, &routines)
+	}()
+
+	if err = enqueMsi(gnmiPath, c, msi, c.prefix); err != nil {
 		log.V(1).Infof("Queue error:  %v", err)
+		enqueFatalMsg(c, err.Error())
 		return
 	}
 	// First sync for this key is done
 	c.synced.Done()
-	for k := range msi {
-		delete(msi, k)
-	}
 	for {
 		select {
 		default:
-			val = nil
-			err = nil
 			c.mu.Lock()
-			if len(msi) > 0 {
-				val, err = msi2TypedValue(msi)
-				for k := range msi {
-					delete(msi, k)
+			n := len(msi)
+			c.mu.Unlock()
+			if n > 0 {
+				if err = enqueMsi(gnmiPath, c, msi, nil); err != nil {
+					enqueFatalMsg(c, err.Error())
+					return
 				}
 			}
-			c.mu.Unlock()
+
+			// check possible value change every 100 millisecond
+			// TODO: make all the instances of wait timer consistent
+			time.Sleep(time.Millisecond * 100)
+		case <-updated:
+			// Ports may be added or removed, subscribe to the new set of table paths
+			updated = c.v2rUpdate(gnmiPath)
+			newPaths, err := c.retranslate(gnmiPath)
+			if err != nil {
+				log.V(2).Infof("Failed to translate %v again: %v", gnmiPath, err)
+			}
+			if sameTblPaths(tblPaths, newPaths) {
+				continue
+			}
+			stopTblPaths(pubsubs, stop, &routines)
+			c.enqueRemoved(gnmiPath, tblPaths, newPaths)
+			tblPaths = newPaths
+			pubsubs, stop, lost, err = resubscribeTblPaths(tblPaths, c, &msi, &routines)
 			if err != nil {
+				log.V(1).Infof("Failed to resubscribe %v: %v", gnmiPath, err)
+				lost <- struct{}{}
+				continue
+			}
+			if len(tblPaths) == 0 {
+				continue
+			}
+			if err = enqueMsi(gnmiPath, c, msi, c.prefix); err != nil {
 				enqueFatalMsg(c, err.Error())
 				return
 			}
-			if val != nil {
-				spbv = &spb.Value{
-					Path:      gnmiPath,
-					Timestamp: time.Now().UnixNano(),
-					Val:       val,
-				}
-
-				log.V(5).Infof("dbTableKeySubscribe enque: %v", spbv)
-				if err = c.q.Put(Value{spbv}); err != nil {
-					log.V(1).Infof("Queue error:  %v", err)
+		case <-lost:
+			// Stop all routines of this path, set up the subscription again
+			// once redis is back and send the full data.
+			stopTblPaths(pubsubs, stop, &routines)
+			pubsubs, stop = nil, make(chan struct{})
+			c.resyncStart(gnmiPath)
+			for {
+				if !waitTblPathsRecovery(c, tblPaths) {
 					return
 				}
+				pubsubs, stop, lost, err = resubscribeTblPaths(tblPaths, c, &msi, &routines)
+				if err == nil {
+					break
+				}
+				log.V(1).Infof("Failed to resubscribe %v: %v", gnmiPath, err)
 			}
-
-			// check possible value change every 100 millisecond
-			// TODO: make all the instances of wait timer consistent
-			time.Sleep(time.Millisecond * 100)
+			if err = enqueMsi(gnmiPath, c, msi, c.prefix); err != nil {
+				enqueFatalMsg(c, err.Error())
+				return
+			}
+			c.resyncDone()
 		case <-c.channel:
 			log.V(1).Infof("Stopping dbTableKeySubscribe routine for %v ", c.pathG2S)
 			return
@@ -1015,10 +1786,100 @@ func dbTableKeySubscribe(gnmiPath *gnmipb.Path, c *DbClient) {
 	}
 }
 
-func  (c *DbClient) Set(path *gnmipb.Path, t *gnmipb.TypedValue, flagop int) error {
-	return nil
+// Set of DB target only validates the path and value against table schema
+// for now, no data is written.
+func (c *DbClient) Set(path *gnmipb.Path, t *gnmipb.TypedValue, flagop int) error {
+	return validateDbSet(c.dbPrefix, path, t, flagop)
 }
-func (c *DbClient) Capabilities() ([]gnmipb.ModelData) {
+
+// validateDbSet checks the path of DB target has the key parts and field in
+// table schema, and the value is of the field type. Paths of tables without
+// schema are not checked.
+func validateDbSet(prefix, path *gnmipb.Path, t *gnmipb.TypedValue, flagop int) error {
+	target := prefix.GetTarget()
+	if _, ok := spb.Target_value[target]; !ok || target == "OTHERS" {
+		return fmt.Errorf("Invalid target name %v", target)
+	}
+	elems := gnmiFullPath(prefix, path).GetElem()
+	if len(elems) == 0 {
+		return fmt.Errorf("Invalid db table Path %v", path)
+	}
+	tableName := elems[0].GetName()
+	schema := getTableSchema(target, tableName)
+	if schema == nil {
+		return nil
+	}
+	var names []string
+	for _, elem := range elems[1:] {
+		names = append(names, elem.GetName())
+	}
+	nKeys := len(schema.Keys)
+	if key, _ := schema.explicitTableKey(tableElemKeys(elems[0]), tableSeparator(target, tableName)); key != "" {
+		nKeys = 0
+	}
+	if len(names) != nKeys && len(names) != nKeys+1 {
+		return fmt.Errorf("Invalid db table Path %v, want keys %v and optional field", path, schema.Keys)
+	}
+	if flagop == DELETE {
+		return nil
+	}
+	if len(names) == nKeys+1 { // value of a field
+		field := names[nKeys]
+		if len(schema.Fields) > 0 {
+			if _, ok := schema.Fields[field]; !ok {
+				return fmt.Errorf("Unknown field %v of %v %v", field, target, tableName)
+			}
+		}
+		return validateField(schema, field, t)
+	}
+	// field value pairs in JSON
+	// Numbers are kept as they are written, ex. 100000 rather than 1e+05
+	var fv map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(t.GetJsonIetfVal()))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fv); err != nil {
+		return fmt.Errorf("Invalid value of %v: %v", path, err)
+	}
+	for field, v := range fv {
+		if len(schema.Fields) > 0 {
+			if _, ok := schema.Fields[field]; !ok {
+				return fmt.Errorf("Unknown field %v of %v %v", field, target, tableName)
+			}
+		}
+		str := fmt.Sprint(v)
+		if n, ok := v.(json.Number); ok {
+			str = n.String()
+		}
+		if err := validateField(schema, field, &gnmipb.TypedValue{
+			Value: &gnmipb.TypedValue_StringVal{StringVal: str}}); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// validateField checks the value is of the field type in schema
+func validateField(schema *tableSchema, field string, t *gnmipb.TypedValue) error {
+	var val string
+	switch v := t.GetValue().(type) {
+	case *gnmipb.TypedValue_StringVal:
+		val = v.StringVal
+	case *gnmipb.TypedValue_UintVal:
+		val = strconv.FormatUint(v.UintVal, 10)
+	case *gnmipb.TypedValue_IntVal:
+		val = strconv.FormatInt(v.IntVal, 10)
+	case *gnmipb.TypedValue_BoolVal:
+		val = strconv.FormatBool(v.BoolVal)
+	case *gnmipb.TypedValue_FloatVal:
+		val = strconv.FormatFloat(float64(v.FloatVal), 'f', -1, 32)
+	default:
+		return fmt.Errorf("Unsupported value %v of field %v", t, field)
+	}
+	if _, err := schema.parseField(field, val); err != nil {
+		return fmt.Errorf("Invalid value %v of field %v, want %v", val, field, schema.fieldType(field))
+	}
+	return nil
+}
+func (c *DbClient) Capabilities() []gnmipb.ModelData {
+	return nil
+}
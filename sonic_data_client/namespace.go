@@ -0,0 +1,219 @@
+package client
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	log "github.com/golang/glog"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+
+	sdcfg "github.com/Azure/sonic-telemetry/sonic_db_config"
+)
+
+// On multi-ASIC platforms, each namespace has its own redis instances. The
+// namespace of a path is selected with "namespace" key of the first path
+// element, ex. PORT_TABLE[namespace=asic0]/Ethernet0, or with origin of the
+// path or prefix, or with the prefix target of the form <namespace>/<DB>,
+// ex. asic0/COUNTERS_DB. Without it, the default namespace is used, which could
+// also be selected with "localhost". Namespace "*" selects all namespaces,
+// the path is expanded into paths of each namespace, except virtual path
+// which is translated into one aggregate view of all namespaces.
+
+const (
+	// Name of the default namespace in path
+	defaultNamespaceName = "localhost"
+	// Path key selecting the namespace
+	namespaceKey = "namespace"
+	// Namespace selecting all namespaces
+	allNamespaces = "*"
+)
+
+// pathNamespace returns the namespace selected for the path, it may be
+// allNamespaces
+func pathNamespace(prefix, path *gnmipb.Path) (string, error) {
+	var ns string
+	var found bool
+	elems := gnmiFullPath(prefix, path).GetElem()
+	if len(elems) > 0 {
+		ns, found = elems[0].GetKey()[namespaceKey]
+	}
+	if !found && path.GetOrigin() != "" {
+		ns, found = path.GetOrigin(), true
+	}
+	if !found && prefix.GetOrigin() != "" {
+		ns, found = prefix.GetOrigin(), true
+	}
+	if !found || ns == defaultNamespaceName {
+		return sdcfg.SONIC_DEFAULT_NAMESPACE, nil
+	}
+	if ns == allNamespaces {
+		return ns, nil
+	}
+	namespaces, err := sdcfg.GetDbNamespaces()
+	if err != nil {
+		return "", err
+	}
+	for _, name := range namespaces {
+		if name == ns {
+			return ns, nil
+		}
+	}
+	return "", fmt.Errorf("Invalid namespace %v", ns)
+}
+
+// SplitDbTarget splits DB target into namespace and DB name. The namespace is
+// empty if not given in target.
+func SplitDbTarget(target string) (string, string) {
+	if i := strings.LastIndex(target, "/"); i >= 0 {
+		return target[:i], target[i+1:]
+	}
+	return "", target
+}
+
+// tableElemKeys returns keys of the table element other than namespace
+func tableElemKeys(elem *gnmipb.PathElem) map[string]string {
+	if _, ok := elem.GetKey()[namespaceKey]; !ok {
+		return elem.GetKey()
+	}
+	keys := make(map[string]string)
+	for k, v := range elem.GetKey() {
+		if k != namespaceKey {
+			keys[k] = v
+		}
+	}
+	return keys
+}
+
+// namespaceElems returns a copy of elems with the namespace key of the first
+// element set to the namespace, or removed if namespace is empty
+func namespaceElems(elems []*gnmipb.PathElem, namespace string) []*gnmipb.PathElem {
+	var nsElems []*gnmipb.PathElem
+	for i, elem := range elems {
+		nsElem := &gnmipb.PathElem{Name: elem.GetName(), Key: elem.GetKey()}
+		if i == 0 {
+			nsElem.Key = tableElemKeys(elem)
+			if namespace != "" {
+				keys := map[string]string{namespaceKey: namespace}
+				for k, v := range nsElem.Key {
+					keys[k] = v
+				}
+				nsElem.Key = keys
+			}
+			if len(nsElem.Key) == 0 {
+				nsElem.Key = nil
+			}
+		}
+		nsElems = append(nsElems, nsElem)
+	}
+	return nsElems
+}
+
+// namespaceName returns the name of namespace used in paths
+func namespaceName(namespace string) string {
+	if namespace == sdcfg.SONIC_DEFAULT_NAMESPACE {
+		return defaultNamespaceName
+	}
+	return namespace
+}
+
+// expandNamespaces populates table paths of the path in each namespace, as
+// the concrete paths tagged with their namespace. The namespace is given by
+// key of the first path element, or by origin if the first element is in
+// prefix. Namespaces without the data are skipped. Virtual paths are not
+// expanded, they give the aggregate view of all namespaces instead.
+func expandNamespaces(conn *RedisConnManager, prefix, path *gnmipb.Path, pathG2S *map[*gnmipb.Path][]tablePath) error {
+	if ok, err := aggregateVirtualPath(conn, prefix, path, pathG2S); ok {
+		return err
+	}
+	nsPrefix := &gnmipb.Path{Target: prefix.GetTarget(), Elem: namespaceElems(prefix.GetElem(), "")}
+	namespaces, err := sdcfg.GetDbNamespaces()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, ns := range namespaces {
+		nsPath := &gnmipb.Path{Target: path.GetTarget()}
+		if len(prefix.GetElem()) > 0 {
+			nsPath.Origin = namespaceName(ns)
+			nsPath.Elem = namespaceElems(path.GetElem(), "")
+		} else {
+			nsPath.Elem = namespaceElems(path.GetElem(), namespaceName(ns))
+		}
+		if err := populateDbtablePath(conn, nsPrefix, nsPath, pathG2S); err != nil {
+			log.V(2).Infof("%v not found in namespace %v: %v", path, namespaceName(ns), err)
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == len(namespaces) {
+		return fmt.Errorf("No valid entry found in any namespace: %v", errs)
+	}
+	return nil
+}
+
+// aggregateVirtualPath translates virtual path with the name maps of each
+// namespace, and puts the table paths of all namespaces together under the
+// path. So ex. COUNTERS[namespace=*]/Ethernet* gives counters of the ports of
+// all ASICs in one update, as names of ports, port channels and vlans are
+// unique across ASICs. It returns false if the path is not a virtual one.
+func aggregateVirtualPath(conn *RedisConnManager, prefix, path *gnmipb.Path, pathG2S *map[*gnmipb.Path][]tablePath) (bool, error) {
+	names := []string{prefix.GetTarget()}
+	for _, elem := range gnmiFullPath(prefix, path).GetElem() {
+		names = append(names, elem.GetName())
+	}
+	if _, ok := v2rTrie.Find(names); !ok {
+		return false, nil
+	}
+	namespaces, err := sdcfg.GetDbNamespaces()
+	if err != nil {
+		return true, err
+	}
+	var tblPaths []tablePath
+	var errs []error
+	for _, ns := range namespaces {
+		v := conn.virtualDb(ns)
+		if err := v.init(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		nsPaths, err := lookupV2R(v.loadMaps(), names)
+		if err != nil {
+			log.V(2).Infof("%v not found in namespace %v: %v", names, namespaceName(ns), err)
+			errs = append(errs, err)
+			continue
+		}
+		for i := range nsPaths {
+			nsPaths[i].namespace = ns
+		}
+		tblPaths = append(tblPaths, nsPaths...)
+	}
+	if len(errs) == len(namespaces) {
+		return true, fmt.Errorf("No valid entry found in any namespace: %v", errs)
+	}
+	(*pathG2S)[path] = tblPaths
+	log.V(5).Infof("v2r from %v to %+v in all namespaces", names, tblPaths)
+	return true, nil
+}
+
+// mapsUpdateAll returns channel closed upon next change of the name maps of
+// any namespace. It stops waiting for the change when done is closed.
+func (m *RedisConnManager) mapsUpdateAll(done <-chan struct{}) <-chan struct{} {
+	namespaces, err := sdcfg.GetDbNamespaces()
+	if err != nil {
+		return nil
+	}
+	cases := []reflect.SelectCase{{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(done)}}
+	for _, ns := range namespaces {
+		cases = append(cases, reflect.SelectCase{
+			Dir:  reflect.SelectRecv,
+			Chan: reflect.ValueOf(m.virtualDb(ns).mapsUpdate()),
+		})
+	}
+	updated := make(chan struct{})
+	go func() {
+		if i, _, _ := reflect.Select(cases); i > 0 {
+			close(updated)
+		}
+	}()
+	return updated
+}
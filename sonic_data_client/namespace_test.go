@@ -0,0 +1,87 @@
+package client
+
+import (
+	"testing"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+
+	sdcfg "github.com/Azure/sonic-telemetry/sonic_db_config"
+)
+
+func TestPathNamespace(t *testing.T) {
+	sdcfg.SetDbConfigFile("../testdata/database_config.json")
+	defer sdcfg.SetDbConfigFile("")
+
+	elem := func(name, ns string) *gnmipb.PathElem {
+		if ns == "" {
+			return &gnmipb.PathElem{Name: name}
+		}
+		return &gnmipb.PathElem{Name: name, Key: map[string]string{namespaceKey: ns}}
+	}
+	tests := []struct {
+		desc    string
+		prefix  *gnmipb.Path
+		path    *gnmipb.Path
+		want    string
+		wantErr bool
+	}{{
+		desc:   "no namespace",
+		prefix: &gnmipb.Path{Target: "COUNTERS_DB"},
+		path:   &gnmipb.Path{Elem: []*gnmipb.PathElem{elem("COUNTERS", ""), elem("Ethernet68", "")}},
+		want:   sdcfg.SONIC_DEFAULT_NAMESPACE,
+	}, {
+		desc:   "default namespace by key",
+		prefix: &gnmipb.Path{Target: "COUNTERS_DB"},
+		path:   &gnmipb.Path{Elem: []*gnmipb.PathElem{elem("COUNTERS", "localhost")}},
+		want:   sdcfg.SONIC_DEFAULT_NAMESPACE,
+	}, {
+		desc:   "namespace by key",
+		prefix: &gnmipb.Path{Target: "COUNTERS_DB"},
+		path:   &gnmipb.Path{Elem: []*gnmipb.PathElem{elem("COUNTERS", "asic0")}},
+		want:   "asic0",
+	}, {
+		desc:   "namespace by key of prefix",
+		prefix: &gnmipb.Path{Target: "COUNTERS_DB", Elem: []*gnmipb.PathElem{elem("COUNTERS", "asic0")}},
+		path:   &gnmipb.Path{Elem: []*gnmipb.PathElem{elem("Ethernet68", "")}},
+		want:   "asic0",
+	}, {
+		desc:   "namespace by path origin",
+		prefix: &gnmipb.Path{Target: "COUNTERS_DB"},
+		path:   &gnmipb.Path{Origin: "asic0", Elem: []*gnmipb.PathElem{elem("COUNTERS", "")}},
+		want:   "asic0",
+	}, {
+		desc:   "namespace by prefix origin",
+		prefix: &gnmipb.Path{Origin: "asic0", Target: "COUNTERS_DB"},
+		path:   &gnmipb.Path{Elem: []*gnmipb.PathElem{elem("COUNTERS", "")}},
+		want:   "asic0",
+	}, {
+		desc:   "key over origin",
+		prefix: &gnmipb.Path{Target: "COUNTERS_DB"},
+		path:   &gnmipb.Path{Origin: "asic0", Elem: []*gnmipb.PathElem{elem("COUNTERS", "localhost")}},
+		want:   sdcfg.SONIC_DEFAULT_NAMESPACE,
+	}, {
+		desc:   "all namespaces",
+		prefix: &gnmipb.Path{Target: "COUNTERS_DB"},
+		path:   &gnmipb.Path{Elem: []*gnmipb.PathElem{elem("COUNTERS", "*")}},
+		want:   allNamespaces,
+	}, {
+		desc:    "invalid namespace",
+		prefix:  &gnmipb.Path{Target: "COUNTERS_DB"},
+		path:    &gnmipb.Path{Elem: []*gnmipb.PathElem{elem("COUNTERS", "asic9")}},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := pathNamespace(tt.prefix, tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got namespace %q, want error", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got namespace %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
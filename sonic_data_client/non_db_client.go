@@ -1,6 +1,7 @@
 package client
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	spb "github.com/Azure/sonic-telemetry/proto"
@@ -17,6 +18,8 @@ import (
 
 const (
 	statsRingCap uint64 = 3000 // capacity of statsRing.
+	// Default interval to sample non db data for stream subscription
+	nonDbSampleInterval = time.Second
 )
 
 type dataGetFunc func() ([]byte, error)
@@ -36,6 +39,11 @@ var (
 	clientTrie *Trie
 	statsR     statsRing
 
+	// sessionsGetter reports the active subscribe sessions of the gNMI server,
+	// it is registered by the server with SetSessionsGetFunc()
+	sessionsGetter dataGetFunc
+	sessionsMu     sync.RWMutex
+
 	// path2DataFuncTbl is used to populate trie tree which is reponsible
 	// for getting data at the path specified
 	path2DataFuncTbl = []path2DataFunc{
@@ -63,6 +71,10 @@ var (
 			path:    []string{"OTHERS", "proc", "stat"},
 			getFunc: dataGetFunc(getProcStat),
 		},
+		{ // Get telemetry subscribe sessions
+			path:    []string{"OTHERS", "telemetry", "sessions"},
+			getFunc: dataGetFunc(getTelemetrySessions),
+		},
 	}
 )
 
@@ -246,6 +258,30 @@ func getProcStat() ([]byte, error) {
 	return b, nil
 }
 
+// SetSessionsGetFunc registers the function which renders the active
+// subscribe sessions in JSON for path OTHERS/telemetry/sessions
+func SetSessionsGetFunc(getter func() ([]byte, error)) {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	sessionsGetter = dataGetFunc(getter)
+}
+
+func getTelemetrySessions() ([]byte, error) {
+	sessionsMu.RLock()
+	getter := sessionsGetter
+	sessionsMu.RUnlock()
+	if getter == nil {
+		return []byte("{}"), nil
+	}
+	b, err := getter()
+	if err != nil {
+		log.V(2).Infof("%v", err)
+		return b, err
+	}
+	log.V(4).Infof("getTelemetrySessions, output %v", string(b))
+	return b, nil
+}
+
 func pollStats() {
 	for {
 		stat, err := linuxproc.ReadStat("/proc/stat")
@@ -330,9 +366,83 @@ func (c *NonDbClient) String() string {
 		c.prefix.GetTarget(), c.sendMsg, c.recvMsg)
 }
 
-// To be implemented
 func (c *NonDbClient) StreamRun(q *queue.PriorityQueue, stop chan struct{}, w *sync.WaitGroup, subscribe *gnmipb.SubscriptionList) {
-	return
+	c.w = w
+	defer c.w.Done()
+	c.q = q
+	c.channel = stop
+
+	subs := make(map[*gnmipb.Path]*gnmipb.Subscription)
+	for _, sub := range subscribe.GetSubscription() {
+		subs[sub.GetPath()] = sub
+	}
+	for gnmiPath, getter := range c.path2Getter {
+		c.w.Add(1)
+		c.synced.Add(1)
+		go nonDbPathSubscribe(gnmiPath, getter, subs[gnmiPath], c)
+	}
+
+	// Wait until all data values corresponding to the path(s) specified
+	// in the SubscriptionList has been transmitted at least once
+	c.synced.Wait()
+	// Inject sync message
+	c.q.Put(Value{
+		&spb.Value{
+			Timestamp:    time.Now().UnixNano(),
+			SyncResponse: true,
+		},
+	})
+	log.V(2).Infof("%v Synced", c)
+	<-c.channel
+	log.V(1).Infof("Exiting StreamRun routine for Client %v", c)
+}
+
+// nonDbPathSubscribe samples data of one path at the subscription interval.
+// For ON_CHANGE or suppress_redundant subscription, data is only put to queue
+// when it differs from the previous sample.
+func nonDbPathSubscribe(gnmiPath *gnmipb.Path, getter dataGetFunc, sub *gnmipb.Subscription, c *NonDbClient) {
+	defer c.w.Done()
+
+	interval := nonDbSampleInterval
+	if sub.GetSampleInterval() > 0 {
+		interval = time.Duration(sub.GetSampleInterval())
+	}
+	onChange := sub.GetMode() == gnmipb.SubscriptionMode_ON_CHANGE || sub.GetSuppressRedundant()
+
+	var last []byte
+	synced := false
+	for {
+		v, err := getter()
+		if err != nil {
+			log.V(3).Infof("StreamRun getter error %v for %v", err, v)
+		} else if !onChange || !synced || !bytes.Equal(v, last) {
+			spbv := &spb.Value{
+				Prefix:    c.prefix,
+				Path:      gnmiPath,
+				Timestamp: time.Now().UnixNano(),
+				Val: &gnmipb.TypedValue{
+					Value: &gnmipb.TypedValue_JsonIetfVal{
+						JsonIetfVal: v,
+					}},
+			}
+			if err = c.q.Put(Value{spbv}); err != nil {
+				log.V(1).Infof("Queue error:  %v", err)
+				return
+			}
+			last = v
+		}
+		if !synced {
+			c.synced.Done()
+			synced = true
+		}
+
+		select {
+		case <-c.channel:
+			log.V(1).Infof("Stopping nonDbPathSubscribe routine for Client %s ", c)
+			return
+		case <-time.After(interval):
+		}
+	}
 }
 
 func (c *NonDbClient) PollRun(q *queue.PriorityQueue, poll chan struct{}, w *sync.WaitGroup) {
@@ -0,0 +1,252 @@
+package client
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+)
+
+// Rates of Ethernet ports are derived from port counters in COUNTERS_DB.
+// Counters of each port read via RATES virtual path are sampled periodically
+// into a ring covering RatesWindow, rates are averaged over the ring.
+
+const (
+	// Interval to sample port counters for rates
+	ratesSampleInterval = time.Second
+)
+
+// RatesWindow is the time window the port rates are averaged over
+var RatesWindow = 10 * time.Second
+
+// Counters sampled for port rates
+var rateCounters = []string{
+	"SAI_PORT_STAT_IF_IN_OCTETS",
+	"SAI_PORT_STAT_IF_OUT_OCTETS",
+	"SAI_PORT_STAT_IF_IN_UCAST_PKTS",
+	"SAI_PORT_STAT_IF_IN_NON_UCAST_PKTS",
+	"SAI_PORT_STAT_IF_OUT_UCAST_PKTS",
+	"SAI_PORT_STAT_IF_OUT_NON_UCAST_PKTS",
+	"SAI_PORT_STAT_IF_IN_ERRORS",
+	"SAI_PORT_STAT_IF_OUT_ERRORS",
+}
+
+type rateSample struct {
+	time     time.Time
+	counters map[string]uint64
+}
+
+// rateRing keeps the samples of one port, oldest first
+type rateRing struct {
+	samples  []rateSample
+	lastRead time.Time
+}
+
+// portRates keeps the rings of ports sampled for rates, keyed by port name
+type portRates struct {
+	mu       sync.Mutex
+	rings    map[string]*rateRing
+	pollOnce sync.Once
+}
+
+// ratesNotReady is the error of reading rates of the port before two samples
+// are taken
+type ratesNotReady string
+
+func (e ratesNotReady) Error() string {
+	return fmt.Sprintf("Rates of %v not ready yet", string(e))
+}
+
+// isRatesNotReady tells whether err is due to rates not sampled yet
+func isRatesNotReady(err error) bool {
+	_, ok := err.(ratesNotReady)
+	return ok
+}
+
+// samplePortCounters reads the rate counters of the port
+func (v *virtualDb) samplePortCounters(name string) (rateSample, error) {
+	sample := rateSample{time: time.Now(), counters: make(map[string]uint64)}
+	oid, ok := v.loadMaps().portNameMap[name]
+	if !ok {
+		return sample, fmt.Errorf("%v not found in COUNTERS_PORT_NAME_MAP", name)
+	}
+	separator, _ := GetTableKeySeparator("COUNTERS_DB")
+	redisDb := v.db("COUNTERS_DB")
+	vals, err := redisDb.HMGet("COUNTERS"+separator+oid, rateCounters...).Result()
+	if err != nil {
+		return sample, err
+	}
+	for i, val := range vals {
+		s, ok := val.(string)
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			continue
+		}
+		sample.counters[rateCounters[i]] = n
+	}
+	return sample, nil
+}
+
+// add appends the sample to the ring, dropping samples older than window
+func (r *rateRing) add(sample rateSample) {
+	r.samples = append(r.samples, sample)
+	i := 0
+	for i < len(r.samples)-2 && sample.time.Sub(r.samples[i+1].time) >= RatesWindow {
+		i++
+	}
+	r.samples = r.samples[i:]
+}
+
+// counterDelta returns increase of counter between two samples.
+// Decrease of counter is taken as wrap of 64 bits counter if it was in upper
+// half, otherwise as reset of counter, ex. by clearing counters or port
+// recreation, counting from zero.
+func counterDelta(prev, cur uint64) uint64 {
+	if cur >= prev {
+		return cur - prev
+	}
+	if prev > math.MaxUint64/2 {
+		return math.MaxUint64 - prev + cur + 1
+	}
+	return cur
+}
+
+// rates computes the port rates over samples in the ring. Deltas are summed
+// between each pair of successive samples, so that wrap or reset of counters
+// in the window is accounted.
+func (r *rateRing) rates(speed string) map[string]interface{} {
+	deltas := make(map[string]uint64)
+	for i := 1; i < len(r.samples); i++ {
+		for _, counter := range rateCounters {
+			prev, ok1 := r.samples[i-1].counters[counter]
+			cur, ok2 := r.samples[i].counters[counter]
+			if ok1 && ok2 {
+				deltas[counter] += counterDelta(prev, cur)
+			}
+		}
+	}
+	secs := r.samples[len(r.samples)-1].time.Sub(r.samples[0].time).Seconds()
+	perSec := func(n uint64) float64 {
+		if secs <= 0 {
+			return 0
+		}
+		return float64(n) / secs
+	}
+	format := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', 2, 64)
+	}
+	// Bits of 64 bits octet counters overflow uint64
+	rxBps := perSec(deltas["SAI_PORT_STAT_IF_IN_OCTETS"]) * 8
+	txBps := perSec(deltas["SAI_PORT_STAT_IF_OUT_OCTETS"]) * 8
+	fv := map[string]interface{}{
+		"RX_BPS":     format(rxBps),
+		"TX_BPS":     format(txBps),
+		"RX_PPS":     format(perSec(deltas["SAI_PORT_STAT_IF_IN_UCAST_PKTS"] + deltas["SAI_PORT_STAT_IF_IN_NON_UCAST_PKTS"])),
+		"TX_PPS":     format(perSec(deltas["SAI_PORT_STAT_IF_OUT_UCAST_PKTS"] + deltas["SAI_PORT_STAT_IF_OUT_NON_UCAST_PKTS"])),
+		"RX_ERR_PPS": format(perSec(deltas["SAI_PORT_STAT_IF_IN_ERRORS"])),
+		"TX_ERR_PPS": format(perSec(deltas["SAI_PORT_STAT_IF_OUT_ERRORS"])),
+		"INTERVAL":   format(secs),
+	}
+	// Port speed in CONFIG_DB is in Mbps
+	if mbps, err := strconv.ParseFloat(speed, 64); err == nil && mbps > 0 {
+		fv["RX_UTIL"] = format(rxBps * 100 / (mbps * 1e6))
+		fv["TX_UTIL"] = format(txBps * 100 / (mbps * 1e6))
+	}
+	return fv
+}
+
+// pollRates samples the counters of ports whose rates are read recently.
+// Ports not read for two windows are no longer sampled. It returns when the
+// virtual DB is closed.
+func (v *virtualDb) pollRates() {
+	for {
+		select {
+		case <-v.stop:
+			return
+		case <-time.After(ratesSampleInterval):
+		}
+		v.rates.mu.Lock()
+		names := make([]string, 0, len(v.rates.rings))
+		for name, r := range v.rates.rings {
+			if time.Since(r.lastRead) > 2*RatesWindow {
+				delete(v.rates.rings, name)
+				continue
+			}
+			names = append(names, name)
+		}
+		v.rates.mu.Unlock()
+		v.samplePorts(names)
+	}
+}
+
+// samplePorts adds a sample of the counters to the rings of the ports. Sample
+// taken less than half a sample interval after the last one is skipped, as
+// rates over such short time are not accurate.
+func (v *virtualDb) samplePorts(names []string) {
+	for _, name := range names {
+		sample, err := v.samplePortCounters(name)
+		if err != nil {
+			log.V(2).Infof("Failed to sample counters of %v: %v", name, err)
+			continue
+		}
+		v.rates.mu.Lock()
+		if r, ok := v.rates.rings[name]; ok {
+			if n := len(r.samples); n == 0 || sample.time.Sub(r.samples[n-1].time) >= ratesSampleInterval/2 {
+				r.add(sample)
+			}
+		}
+		v.rates.mu.Unlock()
+	}
+}
+
+// portRatesTable is virtual table function of port rates, tableKey is the
+// SONiC port name. Upon first read of a port, sampling is started on all
+// ports, as rates of all ports are usually read together. The first samples
+// are taken in background, rates are not ready until the next sample interval.
+func portRatesTable(v *virtualDb, tblPath *tablePath) (map[string]interface{}, error) {
+	v.rates.pollOnce.Do(func() {
+		go v.pollRates()
+	})
+	name := tblPath.tableKey
+
+	v.rates.mu.Lock()
+	r, ok := v.rates.rings[name]
+	if !ok {
+		var names []string
+		for port := range v.loadMaps().portNameMap {
+			if _, ok := v.rates.rings[port]; !ok {
+				v.rates.rings[port] = &rateRing{lastRead: time.Now()}
+				names = append(names, port)
+			}
+		}
+		go v.samplePorts(names)
+		r, ok = v.rates.rings[name]
+	}
+	if !ok {
+		v.rates.mu.Unlock()
+		return nil, fmt.Errorf("%v not found in COUNTERS_PORT_NAME_MAP", name)
+	}
+	r.lastRead = time.Now()
+	ready := len(r.samples) >= 2
+	v.rates.mu.Unlock()
+	if !ready {
+		return nil, ratesNotReady(name)
+	}
+
+	separator, _ := GetTableKeySeparator("CONFIG_DB")
+	speed, err := v.db("CONFIG_DB").HGet("PORT"+separator+name, "speed").Result()
+	if err != nil {
+		log.V(3).Infof("Failed to get speed of %v: %v", name, err)
+	}
+
+	v.rates.mu.Lock()
+	defer v.rates.mu.Unlock()
+	return r.rates(speed), nil
+}
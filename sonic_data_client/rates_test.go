@@ -0,0 +1,62 @@
+package client
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateRingRates(t *testing.T) {
+	start := time.Now()
+	sample := func(secs int, inOctets, inPkts uint64) rateSample {
+		return rateSample{
+			time: start.Add(time.Duration(secs) * time.Second),
+			counters: map[string]uint64{
+				"SAI_PORT_STAT_IF_IN_OCTETS":     inOctets,
+				"SAI_PORT_STAT_IF_IN_UCAST_PKTS": inPkts,
+			},
+		}
+	}
+	format := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', 2, 64)
+	}
+	tests := []struct {
+		desc    string
+		samples []rateSample
+		speed   string
+		want    map[string]string
+	}{{
+		desc:    "increase",
+		samples: []rateSample{sample(0, 1000, 10), sample(1, 2000, 20), sample(2, 3000, 30)},
+		speed:   "100000",
+		want:    map[string]string{"RX_BPS": "8000.00", "RX_PPS": "10.00", "RX_UTIL": "0.00", "INTERVAL": "2.00"},
+	}, {
+		desc:    "reset",
+		samples: []rateSample{sample(0, 1000, 10), sample(1, 500, 5)},
+		want:    map[string]string{"RX_BPS": "4000.00", "RX_PPS": "5.00"},
+	}, {
+		desc:    "wrap",
+		samples: []rateSample{sample(0, math.MaxUint64-99, 0), sample(1, 100, 0)},
+		want:    map[string]string{"RX_BPS": "1600.00"},
+	}, {
+		// Octets counted in bits are beyond uint64
+		desc:    "bits overflow",
+		samples: []rateSample{sample(0, 0, 0), sample(1, math.MaxUint64/4, 0)},
+		want:    map[string]string{"RX_BPS": format(float64(math.MaxUint64/4) * 8)},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			r := &rateRing{}
+			for _, s := range tt.samples {
+				r.add(s)
+			}
+			fv := r.rates(tt.speed)
+			for k, want := range tt.want {
+				if fv[k] != want {
+					t.Errorf("got %v %v, want %v", k, fv[k], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+
+	"github.com/Azure/sonic-telemetry/metrics"
+	sdcfg "github.com/Azure/sonic-telemetry/sonic_db_config"
+)
+
+// RedisConfig tells how to connect to redis of the DBs, whose addresses are
+// in database config.
+type RedisConfig struct {
+	// Connect via tcp port of redis instead of unix socket
+	UseTcp bool
+	// Maximum number of connections to each DB, 10 per CPU if 0
+	PoolSize int
+	// Timeouts of connecting and of socket read and write, redis client
+	// defaults if 0
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// Password of redis AUTH, no AUTH if empty
+	Password string
+}
+
+// RedisConnManager provides redis clients of DBs in each namespace. A client
+// is created upon first use of the DB and shared by the data clients using it.
+// So are the name maps and rates of virtual paths in each namespace.
+type RedisConnManager struct {
+	cfg        RedisConfig
+	mu         sync.RWMutex
+	clients    map[string]*redis.Client
+	virtualDbs map[string]*virtualDb
+}
+
+// defaultRedisConn is used by DB clients created without connection manager
+var defaultRedisConn = NewRedisConnManager(RedisConfig{})
+
+// NewRedisConnManager returns connection manager of the config, no connection
+// is made until the DBs are used.
+func NewRedisConnManager(cfg RedisConfig) *RedisConnManager {
+	return &RedisConnManager{
+		cfg:        cfg,
+		clients:    make(map[string]*redis.Client),
+		virtualDbs: make(map[string]*virtualDb),
+	}
+}
+
+// Config returns the config of the connection manager
+func (m *RedisConnManager) Config() RedisConfig {
+	return m.cfg
+}
+
+// Client returns redis client of the DB in the namespace
+func (m *RedisConnManager) Client(namespace, dbName string) (*redis.Client, error) {
+	key := dbName
+	if namespace != sdcfg.SONIC_DEFAULT_NAMESPACE {
+		key = namespace + "/" + dbName
+	}
+	m.mu.RLock()
+	redisDb, ok := m.clients[key]
+	m.mu.RUnlock()
+	if ok {
+		return redisDb, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if redisDb, ok = m.clients[key]; ok {
+		return redisDb, nil
+	}
+	if !isNamespaceDb(namespace, dbName) {
+		if namespace != sdcfg.SONIC_DEFAULT_NAMESPACE {
+			return nil, fmt.Errorf("Invalid target name %v in namespace %v", dbName, namespace)
+		}
+		return nil, fmt.Errorf("Invalid target name %v", dbName)
+	}
+	// DB number of the namespace in database config
+	dbn, err := sdcfg.GetDbIdNs(namespace, dbName)
+	if err != nil {
+		return nil, err
+	}
+	opts := &redis.Options{
+		Network:      "unix",
+		Password:     m.cfg.Password,
+		DB:           dbn,
+		PoolSize:     m.cfg.PoolSize,
+		DialTimeout:  m.cfg.DialTimeout,
+		ReadTimeout:  m.cfg.ReadTimeout,
+		WriteTimeout: m.cfg.WriteTimeout,
+	}
+	if m.cfg.UseTcp {
+		opts.Network = "tcp"
+		opts.Addr, err = sdcfg.GetDbTcpAddrNs(namespace, dbName)
+	} else {
+		opts.Addr, err = sdcfg.GetDbSockNs(namespace, dbName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	redisDb = redis.NewClient(opts)
+	metrics.InstrumentRedis(dbName, redisDb)
+	m.clients[key] = redisDb
+	return redisDb, nil
+}
+
+// tableDb returns redis client of the table path, whose namespace and DB
+// have been validated when translated from gNMI path
+func (m *RedisConnManager) tableDb(tblPath *tablePath) *redis.Client {
+	redisDb, _ := m.Client(tblPath.namespace, tblPath.dbName)
+	return redisDb
+}
+
+// virtualDb returns virtual DB of the namespace, created upon first use
+func (m *RedisConnManager) virtualDb(namespace string) *virtualDb {
+	m.mu.RLock()
+	v, ok := m.virtualDbs[namespace]
+	m.mu.RUnlock()
+	if ok {
+		return v
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok = m.virtualDbs[namespace]; !ok {
+		v = newVirtualDb(m, namespace)
+		m.virtualDbs[namespace] = v
+	}
+	return v
+}
+
+// Close closes the redis clients created so far and stops watching name maps
+// and sampling rates of virtual paths. They are created and started again
+// upon next use.
+func (m *RedisConnManager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for namespace, v := range m.virtualDbs {
+		v.close()
+		delete(m.virtualDbs, namespace)
+	}
+	var err error
+	for key, redisDb := range m.clients {
+		if cerr := redisDb.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(m.clients, key)
+	}
+	return err
+}
@@ -0,0 +1,243 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	log "github.com/golang/glog"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+
+	spb "github.com/Azure/sonic-telemetry/proto"
+)
+
+// Table schemas describe the layout of redis tables: the names of key parts,
+// the separator, whether the table is a single hash without key, and the
+// types of fields. They are used to resolve paths to table keys and fields,
+// to render field values in JSON and to validate Set. Tables without schema
+// are handled by probing redis. Besides the default ones, schemas could be
+// declared in a JSON descriptor file loaded at startup, keyed by DB and
+// table name. Table name "*" gives the default schema of tables in the DB:
+//
+// {
+//   "tables": {
+//     "CONFIG_DB": {
+//       "PORT": {
+//         "keys": ["name"],
+//         "fields": {"speed": "uint", "mtu": "uint", "lanes": "list"}
+//       }
+//     }
+//   }
+// }
+
+// Field types of table schema
+const (
+	fieldString = "string"
+	fieldUint   = "uint"
+	fieldInt    = "int"
+	fieldBool   = "bool"
+	fieldFloat  = "float"
+	// comma separated values, rendered as JSON array
+	fieldList = "list"
+)
+
+type tableSchema struct {
+	// Names of key parts in order, ex. ["port", "pg"] for BUFFER_PG
+	Keys []string `json:"keys"`
+	// Separator of key parts, the DB separator if empty
+	Separator string `json:"separator"`
+	// Table is a single hash without key, ex. COUNTERS_PORT_NAME_MAP
+	Keyless bool `json:"keyless"`
+	// Field name to its type, fields not listed are strings
+	Fields map[string]string `json:"fields"`
+}
+
+type tableSchemaConfig struct {
+	Tables map[string]map[string]*tableSchema `json:"tables"`
+}
+
+var (
+	// Schemas of tables keyed by DB name, then table name
+	tableSchemas = map[string]map[string]*tableSchema{
+		"COUNTERS_DB": {
+			// Name maps and others are single hashes
+			"*":                     {Keyless: true},
+			"COUNTERS":              {Keys: []string{"oid"}},
+			"USER_WATERMARKS":       {Keys: []string{"oid"}},
+			"PERSISTENT_WATERMARKS": {Keys: []string{"oid"}},
+			"PERIODIC_WATERMARKS":   {Keys: []string{"oid"}},
+		},
+		"CONFIG_DB": {
+			"PORT":               {Keys: []string{"name"}},
+			"BUFFER_PG":          {Keys: []string{"port", "pg"}},
+			"BUFFER_QUEUE":       {Keys: []string{"port", "queue"}},
+			"PORTCHANNEL_MEMBER": {Keys: []string{"lag", "port"}},
+			"VLAN_MEMBER":        {Keys: []string{"vlan", "port"}},
+		},
+	}
+)
+
+// getTableSchema returns schema of the table, or nil if unknown
+func getTableSchema(dbName, tableName string) *tableSchema {
+	tables, ok := tableSchemas[dbName]
+	if !ok {
+		return nil
+	}
+	if schema, ok := tables[tableName]; ok {
+		return schema
+	}
+	return tables["*"]
+}
+
+// tableSeparator returns separator of key parts of the table
+func tableSeparator(dbName, tableName string) string {
+	if schema := getTableSchema(dbName, tableName); schema != nil && schema.Separator != "" {
+		return schema.Separator
+	}
+	separator, _ := GetTableKeySeparator(dbName)
+	return separator
+}
+
+// isKeylessTable tells whether the table is a single hash without key
+func isKeylessTable(dbName, tableName string) bool {
+	schema := getTableSchema(dbName, tableName)
+	return schema != nil && schema.Keyless
+}
+
+// explicitTableKey returns table key given with keys of the table element,
+// either the whole key as "key", ex. ROUTE_TABLE[key=Vrf1:10.0.0.0/24], or
+// each key part by name in schema, ex. BUFFER_PG[port=Ethernet0][pg=3-4].
+func (s *tableSchema) explicitTableKey(elemKeys map[string]string, separator string) (string, error) {
+	if key, ok := elemKeys["key"]; ok {
+		return key, nil
+	}
+	if len(elemKeys) == 0 {
+		return "", nil
+	}
+	if s == nil || len(s.Keys) == 0 {
+		return "", fmt.Errorf("unknown key names %v", elemKeys)
+	}
+	parts := make([]string, len(s.Keys))
+	for i, name := range s.Keys {
+		part, ok := elemKeys[name]
+		if !ok {
+			return "", fmt.Errorf("key %v missing, want keys %v", name, s.Keys)
+		}
+		parts[i] = part
+	}
+	if len(elemKeys) != len(s.Keys) {
+		return "", fmt.Errorf("got keys %v, want keys %v", elemKeys, s.Keys)
+	}
+	return strings.Join(parts, separator), nil
+}
+
+// fieldType returns type of the field
+func (s *tableSchema) fieldType(field string) string {
+	if s == nil {
+		return fieldString
+	}
+	if t, ok := s.Fields[field]; ok {
+		return t
+	}
+	return fieldString
+}
+
+// parseField converts redis value of the field to its type in schema
+func (s *tableSchema) parseField(field, val string) (interface{}, error) {
+	switch s.fieldType(field) {
+	case fieldUint:
+		return strconv.ParseUint(val, 10, 64)
+	case fieldInt:
+		return strconv.ParseInt(val, 10, 64)
+	case fieldBool:
+		return strconv.ParseBool(val)
+	case fieldFloat:
+		return strconv.ParseFloat(val, 64)
+	case fieldList:
+		var list []interface{}
+		for _, v := range strings.Split(val, ",") {
+			list = append(list, v)
+		}
+		return list, nil
+	}
+	return val, nil
+}
+
+// renderField returns value of the field for JSON rendering. Value not of
+// the type in schema is rendered as string as it is.
+func (s *tableSchema) renderField(field, val string) interface{} {
+	v, err := s.parseField(field, val)
+	if err != nil {
+		log.V(3).Infof("%v of %v is not %v: %v", field, val, s.fieldType(field), err)
+		return val
+	}
+	return v
+}
+
+// fieldTypedValue returns gNMI typed value of the field
+func (s *tableSchema) fieldTypedValue(field, val string) *gnmipb.TypedValue {
+	v, err := s.parseField(field, val)
+	if err != nil {
+		v = val
+	}
+	switch v := v.(type) {
+	case uint64:
+		return &gnmipb.TypedValue{Value: &gnmipb.TypedValue_UintVal{UintVal: v}}
+	case int64:
+		return &gnmipb.TypedValue{Value: &gnmipb.TypedValue_IntVal{IntVal: v}}
+	case bool:
+		return &gnmipb.TypedValue{Value: &gnmipb.TypedValue_BoolVal{BoolVal: v}}
+	}
+	// Float is kept as string, FloatVal of 32 bits would lose precision
+	return &gnmipb.TypedValue{Value: &gnmipb.TypedValue_StringVal{StringVal: val}}
+}
+
+// validate checks the table schema
+func (s *tableSchema) validate() error {
+	if s.Keyless && len(s.Keys) > 0 {
+		return fmt.Errorf("keyless table with keys %v", s.Keys)
+	}
+	for field, t := range s.Fields {
+		switch t {
+		case fieldString, fieldUint, fieldInt, fieldBool, fieldFloat, fieldList:
+		default:
+			return fmt.Errorf("field %v of unknown type %v", field, t)
+		}
+	}
+	return nil
+}
+
+// LoadTableSchema loads table schemas from JSON descriptor file, in addition
+// to or replacing the default ones.
+func LoadTableSchema(fileName string) error {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	var cfg tableSchemaConfig
+	if err = json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("invalid table schema %v: %v", fileName, err)
+	}
+	var n int
+	for dbName, tables := range cfg.Tables {
+		if _, ok := spb.Target_value[dbName]; !ok || dbName == "OTHERS" {
+			return fmt.Errorf("invalid table schema %v: unknown db %v", fileName, dbName)
+		}
+		for tableName, schema := range tables {
+			if err = schema.validate(); err != nil {
+				return fmt.Errorf("invalid table schema %v: %v %v %v", fileName, dbName, tableName, err)
+			}
+		}
+		if tableSchemas[dbName] == nil {
+			tableSchemas[dbName] = make(map[string]*tableSchema)
+		}
+		for tableName, schema := range tables {
+			tableSchemas[dbName][tableName] = schema
+			n++
+		}
+	}
+	log.V(1).Infof("Loaded %v table schemas from %v", n, fileName)
+	return nil
+}
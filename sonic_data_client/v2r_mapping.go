@@ -0,0 +1,372 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	log "github.com/golang/glog"
+
+	spb "github.com/Azure/sonic-telemetry/proto"
+)
+
+// Virtual path mappings translate virtual paths to real data paths through
+// a name map table in COUNTERS_DB, ex. COUNTERS_QUEUE_NAME_MAP from queue
+// name to oid. A key element with "*" suffix selects the objects having the
+// prefix. Besides the default ones, mappings could be declared in a JSON file
+// loaded at startup:
+//
+// {
+//   "mappings": [
+//     {
+//       "path": ["COUNTERS_DB", "COUNTERS", "Ethernet*", "Queues"],
+//       "keys": [2],
+//       "name_map": "COUNTERS_QUEUE_NAME_MAP",
+//       "indexed": true,
+//       "alias": "port",
+//       "table": "COUNTERS",
+//       "json_key": "{alias}:{index}"
+//     }
+//   ]
+// }
+
+// Object names in name map are vendor aliased as CONFIG_DB PORT table has
+const aliasPort = "port"
+
+type v2rMapping struct {
+	// Virtual path pattern, element with "*" suffix matches any element having
+	// the prefix. Ex. ["COUNTERS_DB", "COUNTERS", "Ethernet*"]
+	Path []string `json:"path"`
+	// Indexes of path elements which make up the object name in name map,
+	// joined with the DB separator. Ex. [2] for "Ethernet*" above.
+	Keys []int `json:"keys"`
+	// Name map table in COUNTERS_DB from object name to oid
+	NameMap string `json:"name_map"`
+	// Object names have an index after the key elements, ex. "Ethernet0:3"
+	// in COUNTERS_QUEUE_NAME_MAP
+	Indexed bool `json:"indexed"`
+	// Alias source of the first key element, "port" or empty for none
+	Alias string `json:"alias"`
+	// Table holding data of the oids
+	Table string `json:"table"`
+	// Last path element is a field of the table entry, ex.
+	// ["COUNTERS_DB", "COUNTERS", "Ethernet*", "*"]
+	Field bool `json:"field"`
+	// Key of the object in json data for wildcard or indexed object. It is
+	// expanded from {name} and {alias} of the object, {index} and {oid}. For
+	// key elements without wildcard, {alias} is the element as requested.
+	JsonKey string `json:"json_key"`
+}
+
+type v2rConfig struct {
+	Mappings []*v2rMapping `json:"mappings"`
+}
+
+var (
+	// Default virtual path mappings
+	defaultV2rMappings = []*v2rMapping{
+		{ // stats for one or all Ethernet ports
+			Path:    []string{"COUNTERS_DB", "COUNTERS", "Ethernet*"},
+			Keys:    []int{2},
+			NameMap: "COUNTERS_PORT_NAME_MAP",
+			Alias:   aliasPort,
+			Table:   "COUNTERS",
+			JsonKey: "{alias}",
+		}, { // specific field stats for one or all Ethernet ports
+			Path:    []string{"COUNTERS_DB", "COUNTERS", "Ethernet*", "*"},
+			Keys:    []int{2},
+			NameMap: "COUNTERS_PORT_NAME_MAP",
+			Alias:   aliasPort,
+			Table:   "COUNTERS",
+			Field:   true,
+			JsonKey: "{alias}",
+		}, { // Queue stats for one or all Ethernet ports
+			Path:    []string{"COUNTERS_DB", "COUNTERS", "Ethernet*", "Queues"},
+			Keys:    []int{2},
+			NameMap: "COUNTERS_QUEUE_NAME_MAP",
+			Indexed: true,
+			Alias:   aliasPort,
+			Table:   "COUNTERS",
+			JsonKey: "{alias}:{index}",
+		}, { // Priority group stats for one or all Ethernet ports
+			Path:    []string{"COUNTERS_DB", "COUNTERS", "Ethernet*", "PriorityGroups"},
+			Keys:    []int{2},
+			NameMap: "COUNTERS_PG_NAME_MAP",
+			Indexed: true,
+			Alias:   aliasPort,
+			Table:   "COUNTERS",
+			JsonKey: "{alias}:{index}",
+		}, { // Priority group watermarks since last clear by user
+			Path:    []string{"COUNTERS_DB", "COUNTERS", "Ethernet*", "Watermarks"},
+			Keys:    []int{2},
+			NameMap: "COUNTERS_PG_NAME_MAP",
+			Indexed: true,
+			Alias:   aliasPort,
+			Table:   "USER_WATERMARKS",
+			JsonKey: "{alias}:{index}",
+		}, { // Priority group watermarks, persistent ones
+			Path:    []string{"COUNTERS_DB", "COUNTERS", "Ethernet*", "PersistentWatermarks"},
+			Keys:    []int{2},
+			NameMap: "COUNTERS_PG_NAME_MAP",
+			Indexed: true,
+			Alias:   aliasPort,
+			Table:   "PERSISTENT_WATERMARKS",
+			JsonKey: "{alias}:{index}",
+		}, { // Queue watermarks since last clear by user
+			Path:    []string{"COUNTERS_DB", "COUNTERS", "Ethernet*", "Queues", "Watermarks"},
+			Keys:    []int{2},
+			NameMap: "COUNTERS_QUEUE_NAME_MAP",
+			Indexed: true,
+			Alias:   aliasPort,
+			Table:   "USER_WATERMARKS",
+			JsonKey: "{alias}:{index}",
+		}, { // Queue watermarks, persistent ones
+			Path:    []string{"COUNTERS_DB", "COUNTERS", "Ethernet*", "Queues", "PersistentWatermarks"},
+			Keys:    []int{2},
+			NameMap: "COUNTERS_QUEUE_NAME_MAP",
+			Indexed: true,
+			Alias:   aliasPort,
+			Table:   "PERSISTENT_WATERMARKS",
+			JsonKey: "{alias}:{index}",
+		}, { // Buffer pool stats for one or all buffer pools
+			Path:    []string{"COUNTERS_DB", "COUNTERS", "BufferPools", "*"},
+			Keys:    []int{3},
+			NameMap: "COUNTERS_BUFFER_POOL_NAME_MAP",
+			Table:   "COUNTERS",
+			JsonKey: "{name}",
+		}, { // Buffer pool watermarks since last clear by user
+			Path:    []string{"COUNTERS_DB", "COUNTERS", "BufferPools", "*", "Watermarks"},
+			Keys:    []int{3},
+			NameMap: "COUNTERS_BUFFER_POOL_NAME_MAP",
+			Table:   "USER_WATERMARKS",
+			JsonKey: "{name}",
+		}, { // Buffer pool watermarks, persistent ones
+			Path:    []string{"COUNTERS_DB", "COUNTERS", "BufferPools", "*", "PersistentWatermarks"},
+			Keys:    []int{3},
+			NameMap: "COUNTERS_BUFFER_POOL_NAME_MAP",
+			Table:   "PERSISTENT_WATERMARKS",
+			JsonKey: "{name}",
+		}, { // Router interface stats for one or all VLAN interfaces
+			Path:    []string{"COUNTERS_DB", "COUNTERS", "Vlan*"},
+			Keys:    []int{2},
+			NameMap: "COUNTERS_RIF_NAME_MAP",
+			Table:   "COUNTERS",
+			JsonKey: "{name}",
+		}, { // specific field of router interface stats for VLAN interfaces
+			Path:    []string{"COUNTERS_DB", "COUNTERS", "Vlan*", "*"},
+			Keys:    []int{2},
+			NameMap: "COUNTERS_RIF_NAME_MAP",
+			Table:   "COUNTERS",
+			Field:   true,
+			JsonKey: "{name}",
+		}, { // Router interface stats for one or all port channels
+			Path:    []string{"COUNTERS_DB", "COUNTERS", "PortChannel*"},
+			Keys:    []int{2},
+			NameMap: "COUNTERS_RIF_NAME_MAP",
+			Table:   "COUNTERS",
+			JsonKey: "{name}",
+		}, { // specific field of router interface stats for port channels
+			Path:    []string{"COUNTERS_DB", "COUNTERS", "PortChannel*", "*"},
+			Keys:    []int{2},
+			NameMap: "COUNTERS_RIF_NAME_MAP",
+			Table:   "COUNTERS",
+			Field:   true,
+			JsonKey: "{name}",
+		}, { // Router interface stats for one or all Ethernet ports
+			Path:    []string{"COUNTERS_DB", "COUNTERS", "Ethernet*", "Rif"},
+			Keys:    []int{2},
+			NameMap: "COUNTERS_RIF_NAME_MAP",
+			Alias:   aliasPort,
+			Table:   "COUNTERS",
+			JsonKey: "{alias}",
+		}, { // specific field of router interface stats for Ethernet ports
+			Path:    []string{"COUNTERS_DB", "COUNTERS", "Ethernet*", "Rif", "*"},
+			Keys:    []int{2},
+			NameMap: "COUNTERS_RIF_NAME_MAP",
+			Alias:   aliasPort,
+			Table:   "COUNTERS",
+			Field:   true,
+			JsonKey: "{alias}",
+		}, { // ACL rule counters, keyed by ACL table and rule name
+			Path:    []string{"COUNTERS_DB", "ACL", "*", "*"},
+			Keys:    []int{2, 3},
+			NameMap: "ACL_COUNTER_RULE_MAP",
+			Table:   "COUNTERS",
+			JsonKey: "{name}",
+		}, { // specific field of ACL rule counters
+			Path:    []string{"COUNTERS_DB", "ACL", "*", "*", "*"},
+			Keys:    []int{2, 3},
+			NameMap: "ACL_COUNTER_RULE_MAP",
+			Table:   "COUNTERS",
+			Field:   true,
+			JsonKey: "{name}",
+		},
+	}
+
+	// Name map tables used by the mappings, they are read into countersMaps
+	v2rNameMapTables = map[string]bool{
+		"COUNTERS_PORT_NAME_MAP":  true,
+		"COUNTERS_QUEUE_NAME_MAP": true,
+	}
+)
+
+func (mp *v2rMapping) validate() error {
+	if len(mp.Path) < 3 {
+		return fmt.Errorf("path %v shorter than 3 elements", mp.Path)
+	}
+	if _, ok := spb.Target_value[mp.Path[DbIdx]]; !ok || mp.Path[DbIdx] == "OTHERS" {
+		return fmt.Errorf("%v of path %v not a valid DB", mp.Path[DbIdx], mp.Path)
+	}
+	if len(mp.Keys) == 0 {
+		return fmt.Errorf("no keys for path %v", mp.Path)
+	}
+	for _, k := range mp.Keys {
+		if k <= int(TblIdx) || k >= len(mp.Path) || (mp.Field && k == len(mp.Path)-1) {
+			return fmt.Errorf("invalid key index %v for path %v", k, mp.Path)
+		}
+	}
+	if mp.NameMap == "" || mp.Table == "" {
+		return fmt.Errorf("name_map and table required for path %v", mp.Path)
+	}
+	if mp.Alias != "" && mp.Alias != aliasPort {
+		return fmt.Errorf("unknown alias source %v for path %v", mp.Alias, mp.Path)
+	}
+	return nil
+}
+
+// jsonKey expands the json key template for an object
+func (mp *v2rMapping) jsonKey(name, alias, index, oid string) string {
+	return strings.NewReplacer("{name}", name, "{alias}", alias,
+		"{index}", index, "{oid}", oid).Replace(mp.JsonKey)
+}
+
+// translate populates real data paths of the virtual path
+func (mp *v2rMapping) translate(m *countersMaps, paths []string) ([]tablePath, error) {
+	separator, _ := GetTableKeySeparator(paths[DbIdx])
+	nameMap := m.nameMaps[mp.NameMap]
+
+	keys := make([]string, len(mp.Keys))
+	wildcard := false
+	for i, k := range mp.Keys {
+		keys[i] = paths[k]
+		if strings.HasSuffix(keys[i], "*") {
+			wildcard = true
+		}
+	}
+	// Object name as in name map for key elements without wildcard
+	names := append([]string{}, keys...)
+	if mp.Alias == aliasPort {
+		if name, ok := m.alias2nameMap[names[0]]; ok {
+			names[0] = name
+		}
+	}
+
+	newTblPath := func(oid string) tablePath {
+		tblPath := tablePath{
+			dbName:    paths[DbIdx],
+			tableName: mp.Table,
+			tableKey:  oid,
+			delimitor: separator,
+		}
+		if mp.Field {
+			tblPath.field = paths[len(paths)-1]
+			if wildcard {
+				tblPath.jsonField = tblPath.field
+			}
+		}
+		return tblPath
+	}
+
+	if !wildcard && !mp.Indexed { // single object
+		name := strings.Join(names, separator)
+		oid, ok := nameMap[name]
+		if !ok {
+			return nil, fmt.Errorf("%v not found in %v. Requested as %v", name, mp.NameMap, strings.Join(keys, separator))
+		}
+		tblPaths := []tablePath{newTblPath(oid)}
+		log.V(6).Infof("v2r %v: %v", paths, tblPaths)
+		return tblPaths, nil
+	}
+
+	nParts := len(mp.Keys)
+	if mp.Indexed {
+		nParts++
+	}
+	var tblPaths []tablePath
+	for objName, oid := range nameMap {
+		parts := strings.SplitN(objName, separator, nParts)
+		if len(parts) != nParts {
+			continue
+		}
+		aliases := make([]string, len(mp.Keys))
+		matched := true
+		for i, key := range keys {
+			if strings.HasSuffix(key, "*") {
+				if !strings.HasPrefix(parts[i], key[:len(key)-1]) {
+					matched = false
+					break
+				}
+				aliases[i] = parts[i]
+				if i == 0 && mp.Alias == aliasPort {
+					if alias, ok := m.name2aliasMap[parts[i]]; ok {
+						aliases[i] = alias
+					} else {
+						log.V(2).Infof("%v does not have a vendor alias", parts[i])
+					}
+				}
+			} else {
+				if parts[i] != names[i] {
+					matched = false
+					break
+				}
+				aliases[i] = key
+			}
+		}
+		if !matched {
+			continue
+		}
+		var index string
+		if mp.Indexed {
+			index = parts[nParts-1]
+		}
+		tblPath := newTblPath(oid)
+		tblPath.jsonTableKey = mp.jsonKey(strings.Join(parts[:len(mp.Keys)], separator),
+			strings.Join(aliases, separator), index, oid)
+		tblPaths = append(tblPaths, tblPath)
+	}
+	log.V(6).Infof("v2r %v: %v", paths, tblPaths)
+	return tblPaths, nil
+}
+
+// addV2rMapping registers the mapping to v2rTrie, it replaces translation
+// of the same path.
+func addV2rMapping(mp *v2rMapping) error {
+	if err := mp.validate(); err != nil {
+		return err
+	}
+	n := v2rTrie.Add(mp.Path, v2rTranslate(mp.translate))
+	v2rNameMapTables[mp.NameMap] = true
+	log.V(2).Infof("Add trie node for %v with %v", mp.Path, n.meta)
+	return nil
+}
+
+// LoadV2rConfig loads virtual path mappings from the JSON file in addition
+// to the default ones. It is to be called at startup before serving clients.
+func LoadV2rConfig(fileName string) error {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	var cfg v2rConfig
+	if err = json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("invalid virtual path config %v: %v", fileName, err)
+	}
+	for _, mp := range cfg.Mappings {
+		if err = addV2rMapping(mp); err != nil {
+			return fmt.Errorf("invalid virtual path config %v: %v", fileName, err)
+		}
+	}
+	log.V(1).Infof("Loaded %v virtual path mappings from %v", len(cfg.Mappings), fileName)
+	return nil
+}
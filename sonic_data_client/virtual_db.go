@@ -3,7 +3,14 @@ package client
 import (
 	"fmt"
 	log "github.com/golang/glog"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis"
 )
 
 // virtual db is to Handle
@@ -18,50 +25,122 @@ const (
 	FieldIdx             // Field name is the first element (no. 3) in path slice.
 )
 
-type v2rTranslate func([]string) ([]tablePath, error)
+type v2rTranslate func(*countersMaps, []string) ([]tablePath, error)
 
 type pathTransFunc struct {
 	path      []string
 	transFunc v2rTranslate
 }
 
-var (
-	v2rTrie *Trie
+// Wait for burst of name map changes to settle before refreshing the maps,
+// ex. port breakout changes many PORT keys at once.
+const countersMapsSettle = 500 * time.Millisecond
+
+// countersMaps is a snapshot of the name maps virtual paths are translated
+// with. A snapshot is never modified once published, refresh publishes a new
+// one, so translation sees consistent maps without holding any lock.
+type countersMaps struct {
+	// Name map tables of virtual path mappings, ex. COUNTERS_PORT_NAME_MAP
+	// from port name to oid in COUNTERS table of COUNTERS_DB
+	nameMaps map[string]map[string]string
 
 	// Port name to oid map in COUNTERS table of COUNTERS_DB
-	countersPortNameMap = make(map[string]string)
+	portNameMap map[string]string
 
 	// Queue name to oid map in COUNTERS table of COUNTERS_DB
-	countersQueueNameMap = make(map[string]string)
+	queueNameMap map[string]string
 
 	// Alias translation: from vendor port name to sonic interface name
-	alias2nameMap = make(map[string]string)
+	alias2nameMap map[string]string
 	// Alias translation: from sonic interface name to vendor port name
-	name2aliasMap = make(map[string]string)
+	name2aliasMap map[string]string
 
 	// SONiC interface name to their PFC-WD enabled queues, then to oid map
-	countersPfcwdNameMap = make(map[string]map[string]string)
+	pfcwdNameMap map[string]map[string]string
+
+	// Names of port channels and vlans, keyed by their CONFIG_DB table
+	aggregateNames map[string]map[string]bool
+
+	// Closed when the snapshot is replaced by a newer one
+	updated chan struct{}
+}
+
+// virtualDb holds what virtual paths of a namespace are served with, the
+// name maps snapshot, its watcher and rates of ports. It is kept by the
+// connection manager, clients of the same manager share it.
+type virtualDb struct {
+	conn      *RedisConnManager
+	namespace string
+
+	// Current *countersMaps snapshot
+	maps atomic.Value
+	// Serializes refresh of the snapshot
+	mapsMu sync.Mutex
+	// Start watching name map changes along with the first initialization
+	watchOnce sync.Once
+
+	// Counters of ports sampled for rates
+	rates portRates
+
+	// Closed when the connection manager is closed, to stop watching of the
+	// name maps and sampling of rates
+	stop chan struct{}
+}
+
+func newVirtualDb(conn *RedisConnManager, namespace string) *virtualDb {
+	v := &virtualDb{conn: conn, namespace: namespace, stop: make(chan struct{})}
+	v.maps.Store(&countersMaps{updated: make(chan struct{})})
+	v.rates.rings = make(map[string]*rateRing)
+	return v
+}
+
+var (
+	v2rTrie *Trie
 
 	// path2TFuncTbl is used to populate trie tree which is reponsible
-	// for virtual path to real data path translation
+	// for virtual path to real data path translation, in addition to
+	// the virtual path mappings through name maps.
 	pathTransFuncTbl = []pathTransFunc{
-		{ // stats for one or all Ethernet ports
-			path:      []string{"COUNTERS_DB", "COUNTERS", "Ethernet*"},
-			transFunc: v2rTranslate(v2rEthPortStats),
-		}, { // specific field stats for one or all Ethernet ports
-			path:      []string{"COUNTERS_DB", "COUNTERS", "Ethernet*", "*"},
-			transFunc: v2rTranslate(v2rEthPortFieldStats),
-		}, { // Queue stats for one or all Ethernet ports
-			path:      []string{"COUNTERS_DB", "COUNTERS", "Ethernet*", "Queues"},
-			transFunc: v2rTranslate(v2rEthPortQueStats),
-		}, { // PFC WD stats for one or all Ethernet ports
+		{ // PFC WD stats for one or all Ethernet ports
 			path:      []string{"COUNTERS_DB", "COUNTERS", "Ethernet*", "Pfcwd"},
 			transFunc: v2rTranslate(v2rEthPortPfcwdStats),
+		}, { // Port stats summed over members of one or all port channels
+			path:      []string{"COUNTERS_DB", "AGGREGATE", "PortChannel*"},
+			transFunc: v2rTranslate(v2rAggregateStats),
+		}, { // Same as above, with port stats of each member
+			path:      []string{"COUNTERS_DB", "AGGREGATE", "PortChannel*", "Members"},
+			transFunc: v2rTranslate(v2rAggregateStats),
+		}, { // specific field of summed port stats of port channels
+			path:      []string{"COUNTERS_DB", "AGGREGATE", "PortChannel*", "*"},
+			transFunc: v2rTranslate(v2rAggregateStats),
+		}, { // Port stats summed over members of one or all vlans
+			path:      []string{"COUNTERS_DB", "AGGREGATE", "Vlan*"},
+			transFunc: v2rTranslate(v2rAggregateStats),
+		}, { // Same as above, with port stats of each member
+			path:      []string{"COUNTERS_DB", "AGGREGATE", "Vlan*", "Members"},
+			transFunc: v2rTranslate(v2rAggregateStats),
+		}, { // specific field of summed port stats of vlans
+			path:      []string{"COUNTERS_DB", "AGGREGATE", "Vlan*", "*"},
+			transFunc: v2rTranslate(v2rAggregateStats),
+		}, { // Rates of one or all Ethernet ports
+			path:      []string{"COUNTERS_DB", "RATES", "Ethernet*"},
+			transFunc: v2rTranslate(v2rEthPortRates),
+		}, { // specific rate of one or all Ethernet ports
+			path:      []string{"COUNTERS_DB", "RATES", "Ethernet*", "*"},
+			transFunc: v2rTranslate(v2rEthPortRates),
 		},
 	}
+
+	// CONFIG_DB tables of port channels and vlans whose stats are aggregated
+	aggregateTables = []string{"PORTCHANNEL", "VLAN"}
 )
 
 func (t *Trie) v2rTriePopulate() {
+	for _, mp := range defaultV2rMappings {
+		if err := addV2rMapping(mp); err != nil {
+			log.V(1).Infof("Failed to add trie node for %v: %v", mp.Path, err)
+		}
+	}
 	for _, pt := range pathTransFuncTbl {
 		n := t.Add(pt.path, pt.transFunc)
 		if n.meta.(v2rTranslate) == nil {
@@ -73,57 +152,218 @@ func (t *Trie) v2rTriePopulate() {
 	}
 }
 
-func initCountersQueueNameMap() error {
+// loadMaps returns the current name maps snapshot
+func (v *virtualDb) loadMaps() *countersMaps {
+	return v.maps.Load().(*countersMaps)
+}
+
+// refreshMaps reads all the name maps from DB and publishes them as a new
+// snapshot if any changed. Subscriptions on virtual paths are informed by
+// closing updated channel of the previous snapshot.
+func (v *virtualDb) refreshMaps() error {
+	v.mapsMu.Lock()
+	defer v.mapsMu.Unlock()
+
+	var m countersMaps
 	var err error
-	if len(countersQueueNameMap) == 0 {
-		countersQueueNameMap, err = getCountersMap("COUNTERS_QUEUE_NAME_MAP")
+	m.nameMaps = make(map[string]map[string]string)
+	for table := range v2rNameMapTables {
+		m.nameMaps[table], err = v.getCountersMap(table)
+		if err != nil {
+			return err
+		}
+	}
+	m.portNameMap = m.nameMaps["COUNTERS_PORT_NAME_MAP"]
+	m.queueNameMap = m.nameMaps["COUNTERS_QUEUE_NAME_MAP"]
+	m.alias2nameMap, m.name2aliasMap, err = v.getAliasMap()
+	if err != nil {
+		return err
+	}
+	m.pfcwdNameMap, err = v.getPfcwdMap(m.queueNameMap)
+	if err != nil {
+		return err
+	}
+	m.aggregateNames = make(map[string]map[string]bool)
+	for _, table := range aggregateTables {
+		m.aggregateNames[table], err = v.getConfigKeys(table)
 		if err != nil {
 			return err
 		}
 	}
+
+	old := v.loadMaps()
+	if reflect.DeepEqual(m.nameMaps, old.nameMaps) &&
+		reflect.DeepEqual(m.alias2nameMap, old.alias2nameMap) &&
+		reflect.DeepEqual(m.pfcwdNameMap, old.pfcwdNameMap) &&
+		reflect.DeepEqual(m.aggregateNames, old.aggregateNames) {
+		return nil
+	}
+	m.updated = make(chan struct{})
+	v.maps.Store(&m)
+	close(old.updated)
+	log.V(1).Infof("COUNTERS name maps updated: %v ports, %v queues", len(m.portNameMap), len(m.queueNameMap))
 	return nil
 }
 
-func initCountersPortNameMap() error {
-	var err error
-	if len(countersPortNameMap) == 0 {
-		countersPortNameMap, err = getCountersMap("COUNTERS_PORT_NAME_MAP")
-		if err != nil {
+// mapsUpdate returns channel which is closed upon next name maps change
+func (v *virtualDb) mapsUpdate() <-chan struct{} {
+	return v.loadMaps().updated
+}
+
+// init populates the COUNTERS_DB name maps used by virtual paths, and keeps
+// them updated afterwards.
+func (v *virtualDb) init() error {
+	for _, dbName := range []string{"CONFIG_DB", "COUNTERS_DB"} {
+		if _, err := v.conn.Client(v.namespace, dbName); err != nil {
+			return err
+		}
+	}
+	if len(v.loadMaps().portNameMap) == 0 {
+		if err := v.refreshMaps(); err != nil {
 			return err
 		}
 	}
+	v.watchOnce.Do(func() {
+		go v.watchMaps()
+	})
 	return nil
 }
 
-func initAliasMap() error {
-	var err error
-	if len(alias2nameMap) == 0 {
-		alias2nameMap, name2aliasMap, err = getAliasMap()
-		if err != nil {
+// watchMaps refreshes the name maps upon keyspace notification on the tables
+// they are built from, ex. after port breakout, dynamic port add or orchagent
+// restart. Watching is retried with exponential backoff if redis connection
+// is lost, until the virtual DB is closed.
+func (v *virtualDb) watchMaps() {
+	backoff := redisRetryMin
+	for {
+		start := time.Now()
+		err := v.watchMapsOnce()
+		select {
+		case <-v.stop:
+			log.V(1).Infof("Stopped watching COUNTERS name maps of namespace %q", v.namespace)
+			return
+		default:
+		}
+		if time.Since(start) > redisRetryMax {
+			// Watched for a while before the failure
+			backoff = redisRetryMin
+		}
+		log.V(1).Infof("Watching COUNTERS name maps failed: %v, retry in %v", err, backoff)
+		select {
+		case <-v.stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > redisRetryMax {
+			backoff = redisRetryMax
+		}
+	}
+}
+
+// close stops watching of the name maps and sampling of rates
+func (v *virtualDb) close() {
+	close(v.stop)
+}
+
+func (v *virtualDb) watchMapsOnce() error {
+	watched := []struct {
+		dbName string
+		tables []string
+	}{
+		{"CONFIG_DB", []string{"PORT|*", "PFC_WD*", "PORT_QOS_MAP*", "PORTCHANNEL|*", "VLAN|*"}},
+	}
+	var nameMapTables []string
+	for table := range v2rNameMapTables {
+		nameMapTables = append(nameMapTables, table)
+	}
+	watched = append(watched, struct {
+		dbName string
+		tables []string
+	}{"COUNTERS_DB", nameMapTables})
+
+	var pubsubs []*redis.PubSub
+	defer func() {
+		closePubSubs(pubsubs)
+	}()
+	notify := make(chan struct{}, 1)
+	errc := make(chan error, len(watched))
+	for _, w := range watched {
+		// DB number differs among namespaces
+		redisDb := v.db(w.dbName)
+		var patterns []string
+		for _, table := range w.tables {
+			patterns = append(patterns, "__keyspace@"+strconv.Itoa(redisDb.Options().DB)+"__:"+table)
+		}
+		pubsub := redisDb.PSubscribe(patterns...)
+		pubsubs = append(pubsubs, pubsub)
+		for range patterns {
+			msgi, err := pubsub.ReceiveTimeout(time.Second)
+			if err != nil {
+				return fmt.Errorf("psubscribe to %v failed: %v", patterns, err)
+			}
+			if _, ok := msgi.(*redis.Subscription); !ok {
+				return fmt.Errorf("psubscribe to %v failed: %v", patterns, msgi)
+			}
+		}
+		go receiveKeyspace(pubsub, notify, errc)
+	}
+
+	// Changes may have been missed before subscription
+	if err := v.refreshMaps(); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-notify:
+			time.Sleep(countersMapsSettle)
+			select {
+			case <-notify:
+			default:
+			}
+			if err := v.refreshMaps(); err != nil {
+				return err
+			}
+		case err := <-errc:
 			return err
+		case <-v.stop:
+			return nil
 		}
 	}
-	return nil
 }
 
-func initCountersPfcwdNameMap() error {
-	var err error
-	if len(countersPfcwdNameMap) == 0 {
-		countersPfcwdNameMap, err = getPfcwdMap()
+// receiveKeyspace informs notify channel of keyspace notifications of the pubsub
+func receiveKeyspace(pubsub *redis.PubSub, notify chan<- struct{}, errc chan<- error) {
+	for {
+		msgi, err := pubsub.Receive()
 		if err != nil {
-			return err
+			errc <- err
+			return
+		}
+		if _, ok := msgi.(*redis.Message); !ok {
+			continue
+		}
+		select {
+		case notify <- struct{}{}:
+		default:
 		}
 	}
-	return nil
+}
+
+// db returns redis client of the DB for the name maps, rates and virtual
+// tables, which is valid after init succeeded
+func (v *virtualDb) db(dbName string) *redis.Client {
+	redisDb, _ := v.conn.Client(v.namespace, dbName)
+	return redisDb
 }
 
 // Get the mapping between sonic interface name and oids of their PFC-WD enabled queues in COUNTERS_DB
-func getPfcwdMap() (map[string]map[string]string, error) {
+func (v *virtualDb) getPfcwdMap(queueNameMap map[string]string) (map[string]map[string]string, error) {
 	var pfcwdName_map = make(map[string]map[string]string)
 
 	dbName := "CONFIG_DB"
 	separator, _ := GetTableKeySeparator(dbName)
-	redisDb, _ := Target2RedisDb[dbName]
+	redisDb := v.db(dbName)
 	_, err := redisDb.Ping().Result()
 	if err != nil {
 		log.V(1).Infof("Can not connect to %v, err: %v", dbName, err)
@@ -185,7 +425,7 @@ func getPfcwdMap() (map[string]map[string]string, error) {
 		}
 	}
 
-	if len(countersQueueNameMap) == 0 {
+	if len(queueNameMap) == 0 {
 		log.V(1).Infof("COUNTERS_QUEUE_NAME_MAP is empty")
 		return nil, nil
 	}
@@ -195,7 +435,7 @@ func getPfcwdMap() (map[string]map[string]string, error) {
 	for port, _ := range pfcwdName_map {
 		for _, indice := range indices {
 			queue_key = port + queue_separator + indice
-			oid, ok := countersQueueNameMap[queue_key]
+			oid, ok := queueNameMap[queue_key]
 			if !ok {
 				return nil, fmt.Errorf("key %v not exists in COUNTERS_QUEUE_NAME_MAP", queue_key)
 			}
@@ -208,13 +448,13 @@ func getPfcwdMap() (map[string]map[string]string, error) {
 }
 
 // Get the mapping between sonic interface name and vendor alias
-func getAliasMap() (map[string]string, map[string]string, error) {
+func (v *virtualDb) getAliasMap() (map[string]string, map[string]string, error) {
 	var alias2name_map = make(map[string]string)
 	var name2alias_map = make(map[string]string)
 
 	dbName := "CONFIG_DB"
 	separator, _ := GetTableKeySeparator(dbName)
-	redisDb, _ := Target2RedisDb[dbName]
+	redisDb := v.db(dbName)
 	_, err := redisDb.Ping().Result()
 	if err != nil {
 		log.V(1).Infof("Can not connect to %v, err: %v", dbName, err)
@@ -244,10 +484,28 @@ func getAliasMap() (map[string]string, map[string]string, error) {
 	return alias2name_map, name2alias_map, nil
 }
 
+// Get the keys in CONFIG_DB table, ex. port channel names in PORTCHANNEL table
+func (v *virtualDb) getConfigKeys(tableName string) (map[string]bool, error) {
+	dbName := "CONFIG_DB"
+	separator, _ := GetTableKeySeparator(dbName)
+	redisDb := v.db(dbName)
+	keyName := tableName + separator + "*"
+	resp, err := redisDb.Keys(keyName).Result()
+	if err != nil {
+		log.V(1).Infof("redis get keys failed for %v, key = %v, err: %v", dbName, keyName, err)
+		return nil, err
+	}
+	keys := make(map[string]bool)
+	for _, key := range resp {
+		keys[key[len(tableName)+len(separator):]] = true
+	}
+	return keys, nil
+}
+
 // Get the mapping between objects in counters DB, Ex. port name to oid in "COUNTERS_PORT_NAME_MAP" table.
 // Aussuming static port name to oid map in COUNTERS table
-func getCountersMap(tableName string) (map[string]string, error) {
-	redisDb, _ := Target2RedisDb["COUNTERS_DB"]
+func (v *virtualDb) getCountersMap(tableName string) (map[string]string, error) {
+	redisDb := v.db("COUNTERS_DB")
 	fv, err := redisDb.HGetAll(tableName).Result()
 	if err != nil {
 		log.V(2).Infof("redis HGetAll failed for COUNTERS_DB, tableName: %s", tableName)
@@ -257,117 +515,18 @@ func getCountersMap(tableName string) (map[string]string, error) {
 	return fv, nil
 }
 
-// Populate real data paths from paths like
-// [COUNTER_DB COUNTERS Ethernet*] or [COUNTER_DB COUNTERS Ethernet68]
-func v2rEthPortStats(paths []string) ([]tablePath, error) {
-	separator, _ := GetTableKeySeparator(paths[DbIdx])
-	var tblPaths []tablePath
-	if strings.HasSuffix(paths[KeyIdx], "*") { // All Ethernet ports
-		for port, oid := range countersPortNameMap {
-			var oport string
-			if alias, ok := name2aliasMap[port]; ok {
-				oport = alias
-			} else {
-				log.V(2).Infof("%v does not have a vendor alias", port)
-				oport = port
-			}
-
-			tblPath := tablePath{
-				dbName:       paths[DbIdx],
-				tableName:    paths[TblIdx],
-				tableKey:     oid,
-				delimitor:    separator,
-				jsonTableKey: oport,
-			}
-			tblPaths = append(tblPaths, tblPath)
-		}
-	} else { //single port
-		var alias, name string
-		alias = paths[KeyIdx]
-		name = alias
-		if val, ok := alias2nameMap[alias]; ok {
-			name = val
-		}
-		oid, ok := countersPortNameMap[name]
-		if !ok {
-			return nil, fmt.Errorf("%v not a valid sonic interface. Vendor alias is %v", name, alias)
-		}
-		tblPaths = []tablePath{{
-			dbName:    paths[DbIdx],
-			tableName: paths[TblIdx],
-			tableKey:  oid,
-			delimitor: separator,
-		}}
-	}
-	log.V(6).Infof("v2rEthPortStats: %v", tblPaths)
-	return tblPaths, nil
-}
-
-// Supported cases:
-// <1> port name having suffix of "*" with specific field;
-//     Ex. [COUNTER_DB COUNTERS Ethernet* SAI_PORT_STAT_PFC_0_RX_PKTS]
-// <2> exact port name with specific field.
-//     Ex. [COUNTER_DB COUNTERS Ethernet68 SAI_PORT_STAT_PFC_0_RX_PKTS]
-// case of "*" field could be covered in v2rEthPortStats()
-func v2rEthPortFieldStats(paths []string) ([]tablePath, error) {
-	separator, _ := GetTableKeySeparator(paths[DbIdx])
-	var tblPaths []tablePath
-	if strings.HasSuffix(paths[KeyIdx], "*") {
-		for port, oid := range countersPortNameMap {
-			var oport string
-			if alias, ok := name2aliasMap[port]; ok {
-				oport = alias
-			} else {
-				log.V(2).Infof("%v dose not have a vendor alias", port)
-				oport = port
-			}
-
-			tblPath := tablePath{
-				dbName:       paths[DbIdx],
-				tableName:    paths[TblIdx],
-				tableKey:     oid,
-				field:        paths[FieldIdx],
-				delimitor:    separator,
-				jsonTableKey: oport,
-				jsonField:    paths[FieldIdx],
-			}
-			tblPaths = append(tblPaths, tblPath)
-		}
-	} else { //single port
-		var alias, name string
-		alias = paths[KeyIdx]
-		name = alias
-		if val, ok := alias2nameMap[alias]; ok {
-			name = val
-		}
-		oid, ok := countersPortNameMap[name]
-		if !ok {
-			return nil, fmt.Errorf(" %v not a valid sonic interface. Vendor alias is %v ", name, alias)
-		}
-		tblPaths = []tablePath{{
-			dbName:    paths[DbIdx],
-			tableName: paths[TblIdx],
-			tableKey:  oid,
-			field:     paths[FieldIdx],
-			delimitor: separator,
-		}}
-	}
-	log.V(6).Infof("v2rEthPortFieldStats: %+v", tblPaths)
-	return tblPaths, nil
-}
-
 // Populate real data paths from paths like
 // [COUNTER_DB COUNTERS Ethernet* Pfcwd] or [COUNTER_DB COUNTERS Ethernet68 Pfcwd]
-func v2rEthPortPfcwdStats(paths []string) ([]tablePath, error) {
+func v2rEthPortPfcwdStats(m *countersMaps, paths []string) ([]tablePath, error) {
 	separator, _ := GetTableKeySeparator(paths[DbIdx])
 	var tblPaths []tablePath
 	if strings.HasSuffix(paths[KeyIdx], "*") { // Pfcwd on all Ethernet ports
-		for _, pfcqueues := range countersPfcwdNameMap {
+		for _, pfcqueues := range m.pfcwdNameMap {
 			for pfcque, oid := range pfcqueues {
 				// pfcque is in format of "Interface:12"
 				names := strings.Split(pfcque, separator)
 				var oname string
-				if alias, ok := name2aliasMap[names[0]]; ok {
+				if alias, ok := m.name2aliasMap[names[0]]; ok {
 					oname = alias
 				} else {
 					log.V(2).Infof(" %v does not have a vendor alias", names[0])
@@ -387,15 +546,15 @@ func v2rEthPortPfcwdStats(paths []string) ([]tablePath, error) {
 	} else { // pfcwd counters on single port
 		alias := paths[KeyIdx]
 		name := alias
-		if val, ok := alias2nameMap[alias]; ok {
+		if val, ok := m.alias2nameMap[alias]; ok {
 			name = val
 		}
-		_, ok := countersPortNameMap[name]
+		_, ok := m.portNameMap[name]
 		if !ok {
 			return nil, fmt.Errorf("%v not a valid SONiC interface. Vendor alias is %v", name, alias)
 		}
 
-		pfcqueues, ok := countersPfcwdNameMap[name]
+		pfcqueues, ok := m.pfcwdNameMap[name]
 		if ok {
 			for pfcque, oid := range pfcqueues {
 				// pfcque is in format of Ethernet64:12
@@ -416,64 +575,112 @@ func v2rEthPortPfcwdStats(paths []string) ([]tablePath, error) {
 	return tblPaths, nil
 }
 
-// Populate real data paths from paths like
-// [COUNTER_DB COUNTERS Ethernet* Queues] or [COUNTER_DB COUNTERS Ethernet68 Queues]
-func v2rEthPortQueStats(paths []string) ([]tablePath, error) {
+// Populate virtual table paths from paths like
+// [COUNTER_DB AGGREGATE PortChannel*] or [COUNTER_DB AGGREGATE Vlan1000 Members]
+// or [COUNTER_DB AGGREGATE PortChannel0001 SAI_PORT_STAT_IF_IN_OCTETS]
+func v2rAggregateStats(m *countersMaps, paths []string) ([]tablePath, error) {
 	separator, _ := GetTableKeySeparator(paths[DbIdx])
+	key := paths[KeyIdx]
+	configTable, tableName := "VLAN", "VLAN_AGGREGATE"
+	if strings.HasPrefix(key, "PortChannel") {
+		configTable, tableName = "PORTCHANNEL", "PORTCHANNEL_AGGREGATE"
+	}
+	var field string
+	if len(paths) > int(FieldIdx) {
+		if paths[FieldIdx] == "Members" {
+			tableName += "_MEMBERS"
+		} else {
+			field = paths[FieldIdx]
+		}
+	}
+
 	var tblPaths []tablePath
-	if strings.HasSuffix(paths[KeyIdx], "*") { // queues on all Ethernet ports
-		for que, oid := range countersQueueNameMap {
-			// que is in format of "Internal_Ethernet:12"
-			names := strings.Split(que, separator)
-			var oname string
-			if alias, ok := name2aliasMap[names[0]]; ok {
-				oname = alias
-			} else {
-				log.V(2).Infof(" %v dose not have a vendor alias", names[0])
-				oname = names[0]
+	if strings.HasSuffix(key, "*") { // all port channels or vlans
+		for name := range m.aggregateNames[configTable] {
+			if !strings.HasPrefix(name, key[:len(key)-1]) {
+				continue
 			}
-			que = strings.Join([]string{oname, names[1]}, separator)
-			tblPath := tablePath{
+			tblPaths = append(tblPaths, tablePath{
 				dbName:       paths[DbIdx],
-				tableName:    paths[TblIdx],
-				tableKey:     oid,
+				tableName:    tableName,
+				tableKey:     name,
+				field:        field,
 				delimitor:    separator,
-				jsonTableKey: que,
+				jsonTableKey: name,
+				jsonField:    field,
+			})
+		}
+	} else {
+		if !m.aggregateNames[configTable][key] {
+			return nil, fmt.Errorf("%v not found in %v table", key, configTable)
+		}
+		tblPaths = []tablePath{{
+			dbName:    paths[DbIdx],
+			tableName: tableName,
+			tableKey:  key,
+			field:     field,
+			delimitor: separator,
+		}}
+	}
+	log.V(6).Infof("v2rAggregateStats: %v", tblPaths)
+	return tblPaths, nil
+}
+
+// Populate virtual table paths from paths like
+// [COUNTER_DB RATES Ethernet*] or [COUNTER_DB RATES Ethernet68 RX_BPS]
+func v2rEthPortRates(m *countersMaps, paths []string) ([]tablePath, error) {
+	separator, _ := GetTableKeySeparator(paths[DbIdx])
+	var field string
+	if len(paths) > int(FieldIdx) {
+		field = paths[FieldIdx]
+	}
+	var tblPaths []tablePath
+	if strings.HasSuffix(paths[KeyIdx], "*") { // rates of all Ethernet ports
+		for port := range m.portNameMap {
+			var oport string
+			if alias, ok := m.name2aliasMap[port]; ok {
+				oport = alias
+			} else {
+				log.V(2).Infof(" %v does not have a vendor alias", port)
+				oport = port
 			}
-			tblPaths = append(tblPaths, tblPath)
+			tblPaths = append(tblPaths, tablePath{
+				dbName:       paths[DbIdx],
+				tableName:    "PORT_RATES",
+				tableKey:     port,
+				field:        field,
+				delimitor:    separator,
+				jsonTableKey: oport,
+				jsonField:    field,
+			})
 		}
-	} else { //queues on single port
+	} else { // rates of single port
 		alias := paths[KeyIdx]
 		name := alias
-		if val, ok := alias2nameMap[alias]; ok {
+		if val, ok := m.alias2nameMap[alias]; ok {
 			name = val
 		}
-		for que, oid := range countersQueueNameMap {
-			//que is in format of "Ethernet64:12"
-			names := strings.Split(que, separator)
-			if name != names[0] {
-				continue
-			}
-			que = strings.Join([]string{alias, names[1]}, separator)
-			tblPath := tablePath{
-				dbName:       paths[DbIdx],
-				tableName:    paths[TblIdx],
-				tableKey:     oid,
-				delimitor:    separator,
-				jsonTableKey: que,
-			}
-			tblPaths = append(tblPaths, tblPath)
+		if _, ok := m.portNameMap[name]; !ok {
+			return nil, fmt.Errorf("%v not a valid SONiC interface. Vendor alias is %v", name, alias)
 		}
+		tblPaths = []tablePath{{
+			dbName:    paths[DbIdx],
+			tableName: "PORT_RATES",
+			tableKey:  name,
+			field:     field,
+			delimitor: separator,
+		}}
 	}
-	log.V(6).Infof("v2rEthPortQueStats: %v", tblPaths)
+	log.V(6).Infof("v2rEthPortRates: %v", tblPaths)
 	return tblPaths, nil
 }
 
-func lookupV2R(paths []string) ([]tablePath, error) {
+// lookupV2R translates virtual path to real data paths with the name maps
+func lookupV2R(m *countersMaps, paths []string) ([]tablePath, error) {
 	n, ok := v2rTrie.Find(paths)
 	if ok {
 		v2rTrans := n.meta.(v2rTranslate)
-		return v2rTrans(paths)
+		return v2rTrans(m, paths)
 	}
 	return nil, fmt.Errorf("%v not found in virtual path tree", paths)
 }
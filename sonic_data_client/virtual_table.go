@@ -0,0 +1,173 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/golang/glog"
+)
+
+// Virtual tables hold data computed from other data in DB, ex. counters of
+// port channel summed from its member ports. Virtual paths translate to them
+// as to real tables, with tableKey being the object name. They are not backed
+// by redis keys, so stream subscription on them is done by polling.
+
+// virtualTableFunc computes the field value pairs of the object in tblPath
+// with the virtual DB of its namespace
+type virtualTableFunc func(v *virtualDb, tblPath *tablePath) (map[string]interface{}, error)
+
+var virtualTables = map[string]virtualTableFunc{
+	"PORTCHANNEL_AGGREGATE":         aggregateTable(portChannelMembers, false),
+	"PORTCHANNEL_AGGREGATE_MEMBERS": aggregateTable(portChannelMembers, true),
+	"VLAN_AGGREGATE":                aggregateTable(vlanMembers, false),
+	"VLAN_AGGREGATE_MEMBERS":        aggregateTable(vlanMembers, true),
+	"PORT_RATES":                    portRatesTable,
+}
+
+func isVirtualTable(tableName string) bool {
+	_, ok := virtualTables[tableName]
+	return ok
+}
+
+// virtualTableData2Msi renders data of virtual table to msi like tableData2Msi
+func virtualTableData2Msi(conn *RedisConnManager, tblPath *tablePath, msi *map[string]interface{}) error {
+	fv, err := virtualTables[tblPath.tableName](conn.virtualDb(tblPath.namespace), tblPath)
+	if err != nil {
+		return err
+	}
+	if tblPath.field != "" {
+		v, ok := fv[tblPath.field]
+		if !ok {
+			// ignore non-existing field which was derived from virtual path
+			return nil
+		}
+		fv = map[string]interface{}{tblPath.field: v}
+	}
+	if tblPath.jsonTableKey != "" {
+		(*msi)[tblPath.jsonTableKey] = fv
+		return nil
+	}
+	for f, v := range fv {
+		(*msi)[f] = v
+	}
+	return nil
+}
+
+// virtualTableField returns value of the field of virtual table
+func virtualTableField(conn *RedisConnManager, tblPath *tablePath) (string, error) {
+	fv, err := virtualTables[tblPath.tableName](conn.virtualDb(tblPath.namespace), tblPath)
+	if err != nil {
+		return "", err
+	}
+	v, ok := fv[tblPath.field].(string)
+	if !ok {
+		return "", fmt.Errorf("%v doesn't exist for %v", tblPath.field, tblPath.tableKey)
+	}
+	return v, nil
+}
+
+// Get the members of port channel or vlan from keys like
+// "PORTCHANNEL_MEMBER|PortChannel0001|Ethernet0" in the DB table.
+func (v *virtualDb) getMembers(dbName, tableName, name string, members map[string]bool) error {
+	separator, _ := GetTableKeySeparator(dbName)
+	redisDb := v.db(dbName)
+	prefix := tableName + separator + name + separator
+	keys, err := redisDb.Keys(prefix + "*").Result()
+	if err != nil {
+		log.V(2).Infof("redis Keys failed for %v %v*: %v", dbName, prefix, err)
+		return err
+	}
+	for _, key := range keys {
+		members[key[len(prefix):]] = true
+	}
+	return nil
+}
+
+// Members of port channel, both configured and operational ones
+func portChannelMembers(v *virtualDb, name string) (map[string]bool, error) {
+	members := make(map[string]bool)
+	if err := v.getMembers("CONFIG_DB", "PORTCHANNEL_MEMBER", name, members); err != nil {
+		return nil, err
+	}
+	if err := v.getMembers("APPL_DB", "LAG_MEMBER_TABLE", name, members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// Members of vlan, both configured and operational ones
+func vlanMembers(v *virtualDb, name string) (map[string]bool, error) {
+	members := make(map[string]bool)
+	if err := v.getMembers("CONFIG_DB", "VLAN_MEMBER", name, members); err != nil {
+		return nil, err
+	}
+	if err := v.getMembers("APPL_DB", "VLAN_MEMBER_TABLE", name, members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// aggregateTable returns virtual table function summing the port counters of
+// the members. The membership is read each time, so it follows the change.
+// With breakdown, counters of each member are also put under "members",
+// keyed by member port name or vendor alias.
+func aggregateTable(getMembers func(*virtualDb, string) (map[string]bool, error), breakdown bool) virtualTableFunc {
+	return func(v *virtualDb, tblPath *tablePath) (map[string]interface{}, error) {
+		members, err := getMembers(v, tblPath.tableKey)
+		if err != nil {
+			return nil, err
+		}
+		ports := make([]string, 0, len(members))
+		for port := range members {
+			ports = append(ports, port)
+		}
+		sort.Strings(ports)
+
+		m := v.loadMaps()
+		separator, _ := GetTableKeySeparator("COUNTERS_DB")
+		redisDb := v.db("COUNTERS_DB")
+		sums := make(map[string]uint64)
+		memberData := make(map[string]interface{})
+		for _, port := range ports {
+			oid, ok := m.portNameMap[port]
+			if !ok {
+				log.V(2).Infof("%v member %v not found in COUNTERS_PORT_NAME_MAP", tblPath.tableKey, port)
+				continue
+			}
+			data, err := redisDb.HGetAll("COUNTERS" + separator + oid).Result()
+			if err != nil {
+				return nil, err
+			}
+			for f, val := range data {
+				n, err := strconv.ParseUint(strings.TrimSpace(val), 10, 64)
+				if err != nil {
+					// Not a counter
+					continue
+				}
+				sums[f] += n
+			}
+			if breakdown {
+				fv := make(map[string]interface{}, len(data))
+				for f, val := range data {
+					fv[f] = val
+				}
+				alias := port
+				if val, ok := m.name2aliasMap[port]; ok {
+					alias = val
+				}
+				memberData[alias] = fv
+			}
+		}
+
+		fv := make(map[string]interface{}, len(sums)+1)
+		for f, n := range sums {
+			fv[f] = strconv.FormatUint(n, 10)
+		}
+		if breakdown {
+			fv["members"] = memberData
+		}
+		return fv, nil
+	}
+}
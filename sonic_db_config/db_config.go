@@ -4,130 +4,405 @@ package dbconfig
 import (
     "encoding/json"
     "fmt"
+    "os"
+    "path/filepath"
+    "sort"
     "strconv"
+    "sync"
     io "io/ioutil"
 )
 
 const (
     SONIC_DB_CONFIG_FILE string = "/var/run/redis/sonic-db/database_config.json"
+    // On multi-ASIC platforms, it includes database_config.json of each namespace
+    SONIC_DB_GLOBAL_CONFIG_FILE string = "/var/run/redis/sonic-db/database_global.json"
+    // Name of the default namespace, which is the host on multi-ASIC platforms
+    SONIC_DEFAULT_NAMESPACE string = ""
+    // Environment variable overriding path of database_config.json
+    SONIC_DB_CONFIG_FILE_ENV string = "SONIC_DB_CONFIG_FILE"
 )
 
+// Path of database_config.json set by SetDbConfigFile
+var sonic_db_config_file string
 var sonic_db_config = make(map[string]interface{})
+// database_config.json of each namespace other than the default one
+var sonic_db_ns_config = make(map[string]map[string]interface{})
+// Path of database_config.json of each namespace, for error reporting
+var sonic_db_ns_file = make(map[string]string)
 var sonic_db_init bool
+// Error of reading the config files, kept until SetDbConfigFile
+var sonic_db_init_err error
+// Guards all of the above
+var sonic_db_mu sync.RWMutex
 
-func GetDbList()(map[string]interface{}) {
-    if !sonic_db_init {
-        DbInit()
+// SetDbConfigFile sets path of database_config.json, database_global.json is
+// looked for in the same directory. The config is read again upon next use.
+func SetDbConfigFile(file string) {
+    sonic_db_mu.Lock()
+    defer sonic_db_mu.Unlock()
+    sonic_db_config_file = file
+    sonic_db_init = false
+    sonic_db_init_err = nil
+}
+
+// GetDbConfigFile returns path of database_config.json, which is the one set
+// by SetDbConfigFile, or given by environment variable SONIC_DB_CONFIG_FILE,
+// or SONIC_DB_CONFIG_FILE by default.
+func GetDbConfigFile()(string) {
+    sonic_db_mu.RLock()
+    defer sonic_db_mu.RUnlock()
+    return dbConfigFile()
+}
+
+// dbConfigFile is GetDbConfigFile with sonic_db_mu held
+func dbConfigFile()(string) {
+    if sonic_db_config_file != "" {
+        return sonic_db_config_file
+    }
+    if file := os.Getenv(SONIC_DB_CONFIG_FILE_ENV); file != "" {
+        return file
     }
-    db_list, ok := sonic_db_config["DATABASES"].(map[string]interface{})
+    return SONIC_DB_CONFIG_FILE
+}
+
+// getDbConfig returns database_config.json of the namespace
+func getDbConfig(ns string)(map[string]interface{}, string, error) {
+    if err := DbInit(); err != nil {
+        return nil, "", err
+    }
+    sonic_db_mu.RLock()
+    defer sonic_db_mu.RUnlock()
+    if ns == SONIC_DEFAULT_NAMESPACE {
+        return sonic_db_config, dbConfigFile(), nil
+    }
+    config, ok := sonic_db_ns_config[ns]
     if !ok {
-        panic(fmt.Errorf("DATABASES' is not valid key in database_config.json file!"))
+        return nil, "", fmt.Errorf("namespace '%v' is not valid in database_global.json file", ns)
     }
-    return db_list
+    return config, sonic_db_ns_file[ns], nil
 }
 
-func GetDbInst(db_name string)(map[string]interface{}) {
-    if !sonic_db_init {
-        DbInit()
+// getDbField returns field of the database in database_config.json of the namespace
+func getDbField(ns, db_name, field string)(interface{}, error) {
+    db_list, err := GetDbListNs(ns)
+    if err != nil {
+        return nil, err
     }
-    db, ok := sonic_db_config["DATABASES"].(map[string]interface{})[db_name]
+    _, file, _ := getDbConfig(ns)
+    db, ok := db_list[db_name].(map[string]interface{})
     if !ok {
-        panic(fmt.Errorf("database name '%v' is not valid in database_config.json file!", db_name))
+        return nil, fmt.Errorf("database name '%v' is not valid in %v", db_name, file)
     }
-    inst_name, ok := db.(map[string]interface{})["instance"]
+    val, ok := db[field]
     if !ok {
-        panic(fmt.Errorf("'instance' is not a valid field in database_config.json file!"))
+        return nil, fmt.Errorf("'%v' is not a valid field of database '%v' in %v", field, db_name, file)
     }
-    inst, ok := sonic_db_config["INSTANCES"].(map[string]interface{})[inst_name.(string)]
+    return val, nil
+}
+
+// getDbInstField returns field of the redis instance of the database
+func getDbInstField(ns, db_name, field string)(interface{}, error) {
+    inst, err := getDbInstNs(ns, db_name)
+    if err != nil {
+        return nil, err
+    }
+    _, file, _ := getDbConfig(ns)
+    val, ok := inst[field]
     if !ok {
-        panic(fmt.Errorf("instance name '%v' is not valid in database_config.json file!", inst_name))
+        return nil, fmt.Errorf("'%v' is not a valid field of instance of database '%v' in %v", field, db_name, file)
     }
-    return inst.(map[string]interface{})
+    return val, nil
 }
 
-func GetDbSeparator(db_name string)(string) {
-    if !sonic_db_init {
-        DbInit()
+func GetDbList()(map[string]interface{}, error) {
+    return GetDbListNs(SONIC_DEFAULT_NAMESPACE)
+}
+
+func GetDbInst(db_name string)(map[string]interface{}, error) {
+    return getDbInstNs(SONIC_DEFAULT_NAMESPACE, db_name)
+}
+
+func GetDbSeparator(db_name string)(string, error) {
+    return getDbSeparatorNs(SONIC_DEFAULT_NAMESPACE, db_name)
+}
+
+func GetDbId(db_name string)(int, error) {
+    return GetDbIdNs(SONIC_DEFAULT_NAMESPACE, db_name)
+}
+
+func GetDbSock(db_name string)(string, error) {
+    return GetDbSockNs(SONIC_DEFAULT_NAMESPACE, db_name)
+}
+
+func GetDbHostName(db_name string)(string, error) {
+    return getDbHostNameNs(SONIC_DEFAULT_NAMESPACE, db_name)
+}
+
+func GetDbPort(db_name string)(int, error) {
+    return getDbPortNs(SONIC_DEFAULT_NAMESPACE, db_name)
+}
+
+func GetDbTcpAddr(db_name string)(string, error) {
+    return GetDbTcpAddrNs(SONIC_DEFAULT_NAMESPACE, db_name)
+}
+
+// GetDbNamespaces returns the namespaces with databases, the default one first
+func GetDbNamespaces()([]string, error) {
+    if err := DbInit(); err != nil {
+        return nil, err
+    }
+    sonic_db_mu.RLock()
+    defer sonic_db_mu.RUnlock()
+    namespaces := []string{SONIC_DEFAULT_NAMESPACE}
+    var others []string
+    for ns := range sonic_db_ns_config {
+        others = append(others, ns)
+    }
+    sort.Strings(others)
+    return append(namespaces, others...), nil
+}
+
+// IsMultiNamespace tells whether databases of other namespaces are configured
+func IsMultiNamespace()(bool) {
+    if err := DbInit(); err != nil {
+        return false
+    }
+    sonic_db_mu.RLock()
+    defer sonic_db_mu.RUnlock()
+    return len(sonic_db_ns_config) > 0
+}
+
+// GetDbListNs returns the databases of the namespace
+func GetDbListNs(ns string)(map[string]interface{}, error) {
+    config, file, err := getDbConfig(ns)
+    if err != nil {
+        return nil, err
     }
-    db_list := GetDbList()
-    separator, ok := db_list[db_name].(map[string]interface{})["separator"]
+    db_list, ok := config["DATABASES"].(map[string]interface{})
     if !ok {
-        panic(fmt.Errorf("'separator' is not a valid field in database_config.json file!"))
+        return nil, fmt.Errorf("'DATABASES' is not valid key in %v", file)
     }
-    return separator.(string)
+    return db_list, nil
 }
 
-func GetDbId(db_name string)(int) {
-    if !sonic_db_init {
-        DbInit()
+func getDbInstNs(ns, db_name string)(map[string]interface{}, error) {
+    inst_name, err := getDbField(ns, db_name, "instance")
+    if err != nil {
+        return nil, err
+    }
+    config, file, _ := getDbConfig(ns)
+    name, ok := inst_name.(string)
+    if !ok {
+        return nil, fmt.Errorf("'instance' of database '%v' is not a string in %v", db_name, file)
     }
-    db_list := GetDbList()
-    id, ok := db_list[db_name].(map[string]interface{})["id"]
+    insts, ok := config["INSTANCES"].(map[string]interface{})
     if !ok {
-        panic(fmt.Errorf("'id' is not a valid field in database_config.json file!"))
+        return nil, fmt.Errorf("'INSTANCES' is not valid key in %v", file)
     }
-    return int(id.(float64))
+    inst, ok := insts[name].(map[string]interface{})
+    if !ok {
+        return nil, fmt.Errorf("instance name '%v' is not valid in %v", name, file)
+    }
+    return inst, nil
 }
 
-func GetDbSock(db_name string)(string) {
-    if !sonic_db_init {
-        DbInit()
+func getDbSeparatorNs(ns, db_name string)(string, error) {
+    separator, err := getDbField(ns, db_name, "separator")
+    if err != nil {
+        return "", err
     }
-    inst := GetDbInst(db_name)
-    unix_socket_path, ok := inst["unix_socket_path"]
+    s, ok := separator.(string)
     if !ok {
-        panic(fmt.Errorf("'unix_socket_path' is not a valid field in database_config.json file!"))
+        return "", fmt.Errorf("'separator' of database '%v' is not a string", db_name)
     }
-    return unix_socket_path.(string)
+    return s, nil
 }
 
-func GetDbHostName(db_name string)(string) {
-    if !sonic_db_init {
-        DbInit()
+// GetDbIdNs returns the redis DB number of the database in the namespace
+func GetDbIdNs(ns, db_name string)(int, error) {
+    id, err := getDbField(ns, db_name, "id")
+    if err != nil {
+        return 0, err
     }
-    inst := GetDbInst(db_name)
-    hostname, ok := inst["hostname"]
+    n, ok := id.(float64)
     if !ok {
-        panic(fmt.Errorf("'hostname' is not a valid field in database_config.json file!"))
+        return 0, fmt.Errorf("'id' of database '%v' is not a number", db_name)
     }
-    return hostname.(string)
+    return int(n), nil
 }
 
-func GetDbPort(db_name string)(int) {
-    if !sonic_db_init {
-        DbInit()
+// GetDbSockNs returns the unix socket of the database in the namespace
+func GetDbSockNs(ns, db_name string)(string, error) {
+    unix_socket_path, err := getDbInstField(ns, db_name, "unix_socket_path")
+    if err != nil {
+        return "", err
     }
-    inst := GetDbInst(db_name)
-    port, ok := inst["port"]
+    s, ok := unix_socket_path.(string)
     if !ok {
-        panic(fmt.Errorf("'port' is not a valid field in database_config.json file!"))
+        return "", fmt.Errorf("'unix_socket_path' of instance of database '%v' is not a string", db_name)
     }
-    return int(port.(float64))
+    return s, nil
 }
 
-func GetDbTcpAddr(db_name string)(string) {
-    if !sonic_db_init {
-        DbInit()
+func getDbHostNameNs(ns, db_name string)(string, error) {
+    hostname, err := getDbInstField(ns, db_name, "hostname")
+    if err != nil {
+        return "", err
     }
-    hostname := GetDbHostName(db_name)
-    port := GetDbPort(db_name)
-    return hostname + ":" + strconv.Itoa(port)
+    s, ok := hostname.(string)
+    if !ok {
+        return "", fmt.Errorf("'hostname' of instance of database '%v' is not a string", db_name)
+    }
+    return s, nil
 }
 
-func DbInit() {
-    if sonic_db_init {
-        return
+func getDbPortNs(ns, db_name string)(int, error) {
+    port, err := getDbInstField(ns, db_name, "port")
+    if err != nil {
+        return 0, err
     }
-    data, err := io.ReadFile(SONIC_DB_CONFIG_FILE)
+    n, ok := port.(float64)
+    if !ok {
+        return 0, fmt.Errorf("'port' of instance of database '%v' is not a number", db_name)
+    }
+    return int(n), nil
+}
+
+// GetDbTcpAddrNs returns the TCP address of the database in the namespace
+func GetDbTcpAddrNs(ns, db_name string)(string, error) {
+    hostname, err := getDbHostNameNs(ns, db_name)
+    if err != nil {
+        return "", err
+    }
+    port, err := getDbPortNs(ns, db_name)
+    if err != nil {
+        return "", err
+    }
+    return hostname + ":" + strconv.Itoa(port), nil
+}
+
+// ValidateDbConfig checks database_config.json of all namespaces, and returns
+// all the problems found rather than the first one.
+func ValidateDbConfig()([]error) {
+    namespaces, err := GetDbNamespaces()
     if err != nil {
-        panic(err)
-    } else {
-        err = json.Unmarshal([]byte(data), &sonic_db_config)
+        return []error{err}
+    }
+    var errs []error
+    for _, ns := range namespaces {
+        db_list, err := GetDbListNs(ns)
         if err != nil {
-            panic(err)
+            errs = append(errs, err)
+            continue
+        }
+        var db_names []string
+        for db_name := range db_list {
+            db_names = append(db_names, db_name)
         }
+        sort.Strings(db_names)
+        for _, db_name := range db_names {
+            if _, err = getDbSeparatorNs(ns, db_name); err != nil {
+                errs = append(errs, err)
+            }
+            if _, err = GetDbIdNs(ns, db_name); err != nil {
+                errs = append(errs, err)
+            }
+            if _, err = getDbInstNs(ns, db_name); err != nil {
+                errs = append(errs, err)
+                continue
+            }
+            if _, err = GetDbSockNs(ns, db_name); err != nil {
+                errs = append(errs, err)
+            }
+            if _, err = getDbHostNameNs(ns, db_name); err != nil {
+                errs = append(errs, err)
+            }
+            if _, err = getDbPortNs(ns, db_name); err != nil {
+                errs = append(errs, err)
+            }
+        }
+    }
+    return errs
+}
+
+// dbGlobalInit reads database_config.json of each namespace included in
+// database_global.json. The include paths are relative to database_global.json.
+// It is fine without database_global.json on single ASIC platforms. It is
+// called with sonic_db_mu held.
+func dbGlobalInit(global_file string)(error) {
+    data, err := io.ReadFile(global_file)
+    if err != nil {
+        if os.IsNotExist(err) {
+            return nil
+        }
+        return err
+    }
+    var global struct {
+        Includes []struct {
+            Namespace string `json:"namespace"`
+            Include   string `json:"include"`
+        } `json:"INCLUDES"`
+    }
+    err = json.Unmarshal(data, &global)
+    if err != nil {
+        return fmt.Errorf("invalid %v: %v", global_file, err)
+    }
+    for _, entry := range global.Includes {
+        if entry.Namespace == SONIC_DEFAULT_NAMESPACE {
+            // database_config.json of default namespace is read already
+            continue
+        }
+        file := filepath.Join(filepath.Dir(global_file), entry.Include)
+        data, err = io.ReadFile(file)
+        if err != nil {
+            return err
+        }
+        config := make(map[string]interface{})
+        err = json.Unmarshal(data, &config)
+        if err != nil {
+            return fmt.Errorf("invalid %v: %v", file, err)
+        }
+        sonic_db_ns_config[entry.Namespace] = config
+        sonic_db_ns_file[entry.Namespace] = file
+    }
+    return nil
+}
+
+// DbInit reads the database config files once. The result, error included, is
+// kept until the file is set again by SetDbConfigFile.
+func DbInit()(error) {
+    sonic_db_mu.RLock()
+    done, err := sonic_db_init, sonic_db_init_err
+    sonic_db_mu.RUnlock()
+    if done {
+        return err
+    }
+
+    sonic_db_mu.Lock()
+    defer sonic_db_mu.Unlock()
+    if !sonic_db_init {
+        sonic_db_init_err = dbInit()
         sonic_db_init = true
     }
+    return sonic_db_init_err
+}
+
+// dbInit reads the database config files with sonic_db_mu held
+func dbInit()(error) {
+    file := dbConfigFile()
+    data, err := io.ReadFile(file)
+    if err != nil {
+        return err
+    }
+    config := make(map[string]interface{})
+    err = json.Unmarshal([]byte(data), &config)
+    if err != nil {
+        return fmt.Errorf("invalid %v: %v", file, err)
+    }
+    sonic_db_config = config
+    sonic_db_ns_config = make(map[string]map[string]interface{})
+    sonic_db_ns_file = make(map[string]string)
+    return dbGlobalInit(filepath.Join(filepath.Dir(file), filepath.Base(SONIC_DB_GLOBAL_CONFIG_FILE)))
 }
 
 func init() {
@@ -0,0 +1,178 @@
+package dbconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestDbGlobalInit(t *testing.T) {
+	SetDbConfigFile("../testdata/database_config.json")
+	defer SetDbConfigFile("")
+
+	namespaces, err := GetDbNamespaces()
+	if err != nil {
+		t.Fatalf("Failed to read database_global.json: %v", err)
+	}
+	if want := []string{SONIC_DEFAULT_NAMESPACE, "asic0"}; !reflect.DeepEqual(namespaces, want) {
+		t.Errorf("got namespaces %q, want %q", namespaces, want)
+	}
+	if !IsMultiNamespace() {
+		t.Errorf("got single namespace with database_global.json")
+	}
+	tests := []struct {
+		ns     string
+		dbName string
+		want   int
+	}{
+		{SONIC_DEFAULT_NAMESPACE, "COUNTERS_DB", 2},
+		{"asic0", "COUNTERS_DB", 10},
+		{"asic0", "CONFIG_DB", 12},
+	}
+	for _, tt := range tests {
+		id, err := GetDbIdNs(tt.ns, tt.dbName)
+		if err != nil || id != tt.want {
+			t.Errorf("GetDbIdNs(%q, %v) = %v, %v, want %v", tt.ns, tt.dbName, id, err, tt.want)
+		}
+	}
+	if _, err = GetDbIdNs("asic9", "COUNTERS_DB"); err == nil {
+		t.Errorf("got DB id of unknown namespace asic9")
+	}
+}
+
+func TestDbConfigValidation(t *testing.T) {
+	badFile, err := ioutil.TempFile("", "database_config")
+	if err != nil {
+		t.Fatalf("Failed to create database config file: %v", err)
+	}
+	defer os.Remove(badFile.Name())
+	badFile.WriteString(`{
+		"INSTANCES": {"redis": {"hostname": "127.0.0.1", "port": 6379, "unix_socket_path": "/var/run/redis/redis.sock"}},
+		"DATABASES": {
+			"APPL_DB": {"id": 0, "separator": ":", "instance": "redis"},
+			"CONFIG_DB": {"id": 4, "separator": "|", "instance": "redis1"},
+			"STATE_DB": {"separator": 6, "instance": "redis"}
+		}
+	}`)
+	badFile.Close()
+
+	// All problems are reported, without panic
+	SetDbConfigFile(badFile.Name())
+	defer SetDbConfigFile("")
+	errs := ValidateDbConfig()
+	if len(errs) != 3 {
+		t.Errorf("got %v problems %v, want 3", len(errs), errs)
+	}
+	if _, err = GetDbSock("CONFIG_DB"); err == nil {
+		t.Errorf("got socket of CONFIG_DB with invalid instance")
+	}
+	if _, err = GetDbSeparator("STATE_DB"); err == nil {
+		t.Errorf("got separator of STATE_DB of invalid type")
+	}
+
+	SetDbConfigFile("/nonexistent/database_config.json")
+	errs = ValidateDbConfig()
+	if len(errs) != 1 {
+		t.Errorf("got %v problems %v of missing database config, want 1", len(errs), errs)
+	}
+}
+
+func TestDbInitOnce(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sonic-db")
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "database_config.json")
+	SetDbConfigFile(file)
+	defer SetDbConfigFile("")
+
+	// Missing file is not read again until the file is set again
+	if err = DbInit(); err == nil {
+		t.Fatalf("got no error of missing %v", file)
+	}
+	data, err := ioutil.ReadFile("../testdata/database_config.json")
+	if err != nil {
+		t.Fatalf("Failed to read database config: %v", err)
+	}
+	if err = ioutil.WriteFile(file, data, 0644); err != nil {
+		t.Fatalf("Failed to write %v: %v", file, err)
+	}
+	if err = DbInit(); err == nil {
+		t.Errorf("got no error after the file is created")
+	}
+	SetDbConfigFile(file)
+	if err = DbInit(); err != nil {
+		t.Errorf("got error after the file is set again: %v", err)
+	}
+
+	// Concurrent use while the file is set again
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			SetDbConfigFile(file)
+			if _, err := GetDbIdNs(SONIC_DEFAULT_NAMESPACE, "COUNTERS_DB"); err != nil {
+				t.Errorf("Failed to get id of COUNTERS_DB: %v", err)
+			}
+			GetDbNamespaces()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestDbGlobalInitErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sonic-db")
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	global := filepath.Join(dir, "database_global.json")
+	defer func() {
+		sonic_db_ns_config = make(map[string]map[string]interface{})
+		sonic_db_ns_file = make(map[string]string)
+	}()
+
+	// Single ASIC platforms have no database_global.json
+	if err = dbGlobalInit(global); err != nil {
+		t.Errorf("got error without database_global.json: %v", err)
+	}
+
+	tests := []struct {
+		desc   string
+		global string
+		asic0  string
+	}{{
+		desc:   "invalid database_global.json",
+		global: `{"INCLUDES": [`,
+	}, {
+		desc:   "missing include",
+		global: `{"INCLUDES": [{"namespace": "asic0", "include": "asic0/database_config.json"}]}`,
+	}, {
+		desc:   "invalid include",
+		global: `{"INCLUDES": [{"namespace": "asic0", "include": "asic0/database_config.json"}]}`,
+		asic0:  `{"DATABASES": `,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			if err := ioutil.WriteFile(global, []byte(tt.global), 0644); err != nil {
+				t.Fatalf("Failed to write %v: %v", global, err)
+			}
+			os.RemoveAll(filepath.Join(dir, "asic0"))
+			if tt.asic0 != "" {
+				os.Mkdir(filepath.Join(dir, "asic0"), 0755)
+				file := filepath.Join(dir, "asic0", "database_config.json")
+				if err := ioutil.WriteFile(file, []byte(tt.asic0), 0644); err != nil {
+					t.Fatalf("Failed to write %v: %v", file, err)
+				}
+			}
+			if err := dbGlobalInit(global); err == nil {
+				t.Errorf("got no error")
+			}
+		})
+	}
+}
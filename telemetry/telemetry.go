@@ -5,12 +5,20 @@ import (
 	"crypto/x509"
 	"flag"
 	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	log "github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 
 	gnmi "github.com/Azure/sonic-telemetry/gnmi_server"
+	"github.com/Azure/sonic-telemetry/metrics"
+	sdc "github.com/Azure/sonic-telemetry/sonic_data_client"
 	testcert "github.com/Azure/sonic-telemetry/testdata/tls"
 )
 
@@ -23,6 +31,20 @@ var (
 	insecure          = flag.Bool("insecure", false, "Skip providing TLS cert and key, for testing only!")
 	allowNoClientCert = flag.Bool("allow_no_client_auth", false, "When set, telemetry server will request but not require a client certificate.")
 	useRedisLocal     = flag.Bool("redis_local", false, "Connect redis via local tcp socket")
+	metricsPort       = flag.Int("metrics_port", 0, "Port to serve Prometheus metrics on. Disabled if 0")
+	shutdownTimeout   = flag.Duration("shutdown_timeout", 10*time.Second, "Time to wait for active sessions to close on SIGTERM before stopping forcibly")
+	countersExporter  = flag.Bool("counters_exporter", false, "Also export COUNTERS_DB port, queue and PFC watchdog counters at /counters of the metrics port")
+	v2rConfig         = flag.String("v2r_config", "", "JSON file of virtual path mappings in addition to the default ones")
+	notifyRedisGap    = flag.Bool("notify_redis_gap", false, "Send update of /telemetry/redis_gap with the affected subscribed path when redis connection is lost, data is resent after recovery")
+	tableSchema       = flag.String("table_schema", "", "JSON descriptor file of redis table schemas in addition to the default ones")
+	ratesWindow       = flag.Duration("rates_window", 10*time.Second, "Time window port rates of RATES virtual path are averaged over")
+	wildcardLimit     = flag.Int("wildcard_limit", 10000, "Maximum number of redis keys and paths a wildcard path of DB target is expanded to")
+	dbConfig          = flag.String("db_config", "", "Path of database_config.json, overriding environment variable SONIC_DB_CONFIG_FILE and the default path")
+	redisPoolSize     = flag.Int("redis_pool_size", 0, "Maximum number of connections to each redis DB, 10 per CPU if 0")
+	redisDialTimeout  = flag.Duration("redis_dial_timeout", 0, "Timeout of connecting to redis, 5s if 0")
+	redisReadTimeout  = flag.Duration("redis_read_timeout", 0, "Timeout of redis socket reads, 3s if 0")
+	redisWriteTimeout = flag.Duration("redis_write_timeout", 0, "Timeout of redis socket writes, same as read timeout if 0")
+	redisPasswordFile = flag.String("redis_password_file", "", "File containing password of redis AUTH. No AUTH if not set")
 )
 
 func main() {
@@ -97,14 +119,79 @@ func main() {
 	cfg := &gnmi.Config{}
 	cfg.Port = int64(*port)
 	log.V(1).Infof("Config is : %v", cfg)
-	s, err := gnmi.NewServer(cfg, opts, *useRedisLocal)
+	// DB targets are unavailable with invalid database config, others still work
+	for _, err := range sdc.InitDbConfig(*dbConfig) {
+		log.Errorf("Invalid database config: %v", err)
+	}
+	sdc.NotifyRedisGap = *notifyRedisGap
+	sdc.RatesWindow = *ratesWindow
+	sdc.WildcardLimit = *wildcardLimit
+	if *v2rConfig != "" {
+		if err := sdc.LoadV2rConfig(*v2rConfig); err != nil {
+			log.Errorf("Failed to load virtual path config: %v", err)
+			return
+		}
+	}
+	if *tableSchema != "" {
+		if err := sdc.LoadTableSchema(*tableSchema); err != nil {
+			log.Errorf("Failed to load table schema: %v", err)
+			return
+		}
+	}
+	redisCfg := sdc.RedisConfig{
+		UseTcp:       *useRedisLocal,
+		PoolSize:     *redisPoolSize,
+		DialTimeout:  *redisDialTimeout,
+		ReadTimeout:  *redisReadTimeout,
+		WriteTimeout: *redisWriteTimeout,
+	}
+	if *redisPasswordFile != "" {
+		password, err := ioutil.ReadFile(*redisPasswordFile)
+		if err != nil {
+			log.Errorf("Failed to read redis password: %v", err)
+			return
+		}
+		redisCfg.Password = strings.TrimSpace(string(password))
+	}
+	conn := sdc.NewRedisConnManager(redisCfg)
+	defer conn.Close()
+	s, err := gnmi.NewServer(cfg, opts, conn)
 	if err != nil {
 		log.Errorf("Failed to create gNMI server: %v", err)
 		return
 	}
 
+	if *metricsPort > 0 {
+		var counters prometheus.Collector
+		if *countersExporter {
+			counters = sdc.NewCountersCollector(conn)
+		}
+		go func() {
+			if err := metrics.Serve(*metricsPort, counters); err != nil {
+				log.Errorf("Failed to serve metrics: %v", err)
+			}
+		}()
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+	errc := make(chan error, 1)
+
 	log.V(1).Infof("Starting RPC server on address: %s", s.Address())
-	s.Serve() // blocks until close
+	go func() {
+		errc <- s.Serve() // blocks until close
+	}()
+
+	select {
+	case sig := <-sigs:
+		log.V(1).Infof("Received %v, stopping telemetry server", sig)
+		s.GracefulStop(*shutdownTimeout)
+		<-errc
+	case err := <-errc:
+		if err != nil {
+			log.Errorf("Telemetry server failed: %v", err)
+		}
+	}
 	log.V(1).Infof("Exiting telemetry server")
 	log.Flush()
 }